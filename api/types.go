@@ -0,0 +1,209 @@
+// Package api defines the JSON types of the server's HTTP API and embeds its
+// OpenAPI specification, which the server serves at /openapi.yaml.
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"time"
+)
+
+// Spec is the OpenAPI 3 specification of the server's HTTP API, in YAML.
+//
+//go:embed openapi.yaml
+var Spec []byte
+
+// Error codes. Clients should branch on the code rather than the message.
+const (
+	CodeInvalidRequest      = "invalid_request"
+	CodeInvalidDocument     = "invalid_document"
+	CodeUnauthorized        = "unauthorized"
+	CodeForbidden           = "forbidden"
+	CodeNotFound            = "not_found"
+	CodeMethodNotAllowed    = "method_not_allowed"
+	CodeNotConfigured       = "not_configured"
+	CodeConflict            = "conflict"
+	CodeQueueFull           = "queue_full"
+	CodeShuttingDown        = "shutting_down"
+	CodeCanceled            = "canceled"
+	CodeModelNotFound       = "model_not_found"
+	CodeUpstreamError       = "upstream_error"
+	CodeUpstreamUnavailable = "upstream_unavailable"
+	CodeUpstreamTimeout     = "upstream_timeout"
+	CodeInternal            = "internal"
+)
+
+// ErrorResponse is the body of every error response.
+type ErrorResponse struct {
+	Error Error `json:"error"`
+}
+
+// Error describes a failed request or job. Retryable reports whether the same
+// request may succeed later. RequestID matches the request_id of the server's
+// log records for the request.
+type Error struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Analysis modes accepted by POST /analyze.
+const (
+	ModeText       = "text"
+	ModeStructured = "structured"
+)
+
+// AnalyzeResponse is the result of a text analysis: a free-text description of
+// each page.
+type AnalyzeResponse struct {
+	Filename string         `json:"filename"`
+	Pages    []PageResponse `json:"pages"`
+}
+
+// PageResponse is one page of an AnalyzeResponse.
+type PageResponse struct {
+	Page     int    `json:"page"`
+	Analysis string `json:"analysis"`
+}
+
+// StructuredResponse is the result of a structured analysis: the per-page
+// analyses merged the way the batch processor merges them.
+type StructuredResponse struct {
+	Filename string           `json:"filename"`
+	Pages    int              `json:"pages"`
+	Analysis DocumentAnalysis `json:"analysis"`
+}
+
+// DocumentAnalysis is the metadata extracted from a document.
+type DocumentAnalysis struct {
+	Summary       string   `json:"summary"`
+	Transcription string   `json:"transcription"`
+	FileName      string   `json:"file_name"`
+	DocumentType  string   `json:"document_type"`
+	DocumentDate  string   `json:"document_date"`
+	Correspondent string   `json:"correspondent"`
+	Tags          []string `json:"tags"`
+	StoragePath   string   `json:"storage_path,omitempty"`
+}
+
+// JobStatus is the lifecycle state of an analysis job.
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCanceled  JobStatus = "canceled"
+)
+
+// Done reports whether the status is final.
+func (s JobStatus) Done() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCanceled
+}
+
+// JobResponse is returned when an analysis is queued.
+type JobResponse struct {
+	JobID     string    `json:"job_id"`
+	Status    JobStatus `json:"status"`
+	StatusURL string    `json:"status_url"`
+	ResultURL string    `json:"result_url"`
+}
+
+// JobInfo is a job's status and per-page progress.
+type JobInfo struct {
+	ID         string         `json:"id"`
+	Status     JobStatus      `json:"status"`
+	Created    time.Time      `json:"created"`
+	Started    *time.Time     `json:"started,omitempty"`
+	Finished   *time.Time     `json:"finished,omitempty"`
+	PagesTotal int            `json:"pages_total"`
+	PagesDone  int            `json:"pages_done"`
+	Pages      []PageProgress `json:"pages"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// PageProgress is the progress of one page of a job: pending, running, done or failed.
+type PageProgress struct {
+	Page       int    `json:"page"`
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+}
+
+// JobResult is a finished job's outcome. Result holds an AnalyzeResponse or a
+// StructuredResponse, depending on the mode; Error is set if the job failed.
+type JobResult struct {
+	Status JobStatus       `json:"status"`
+	Error  *Error          `json:"error,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// Document is a Paperless-ngx document as listed by GET /documents.
+type Document struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+// ProcessRequest is the optional body of POST /documents/{id}/process.
+type ProcessRequest struct {
+	// DryRun analyzes the document and reports the changes without saving them or
+	// creating correspondents, tags or custom fields.
+	DryRun bool `json:"dry_run"`
+	// Fields limits the fields updated, as UPDATE_FIELDS does for the batch. Empty
+	// means all. storage_path and permissions need the server's rule flags.
+	Fields []string `json:"fields"`
+	// Model overrides the server's Ollama model. It must be one of the server's
+	// -process-models.
+	Model string `json:"model"`
+}
+
+// ProcessResponse reports a processed document's analysis and changes. Changes
+// are the fields the update sent, or in a dry run would send.
+type ProcessResponse struct {
+	DocumentID int              `json:"document_id"`
+	DryRun     bool             `json:"dry_run"`
+	Model      string           `json:"model"`
+	Analysis   DocumentAnalysis `json:"analysis"`
+	Changes    []FieldChange    `json:"changes"`
+	Skipped    []SkippedField   `json:"skipped,omitempty"`
+	Updated    bool             `json:"updated"`
+}
+
+// FieldChange is one field that differs before and after processing.
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// SkippedField is a field the analysis could not set.
+type SkippedField struct {
+	Field  string `json:"field"`
+	Value  string `json:"value,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// Dependency check statuses in a ReadyResponse.
+const (
+	CheckOK            = "ok"
+	CheckFailed        = "failed"
+	CheckNotConfigured = "not_configured"
+)
+
+// ReadyResponse is the result of GET /ready.
+type ReadyResponse struct {
+	Ready        bool                   `json:"ready"`
+	ShuttingDown bool                   `json:"shutting_down,omitempty"`
+	Dependencies map[string]CheckResult `json:"dependencies"`
+}
+
+// CheckResult is the status of one dependency.
+type CheckResult struct {
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms,omitempty"`
+	Model     string `json:"model,omitempty"`
+	// Error is the classified reason a failed check failed, as in error
+	// responses; the underlying error is only logged.
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,305 @@
+// Package client is a Go client for the server's HTTP API. It builds the
+// multipart uploads, follows analysis jobs to completion and decodes responses
+// into the types of package api.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bartlettc22/paperless-llm-processor/api"
+)
+
+// DefaultPollInterval is how often Wait polls a job's status.
+const DefaultPollInterval = time.Second
+
+type Client struct {
+	BaseURL string
+	// Token is sent as a bearer token when set.
+	Token string
+	// Username and Password are sent as basic auth credentials when Token is empty.
+	Username string
+	Password string
+	HTTP     *http.Client
+	// PollInterval is how often Wait polls a job's status.
+	PollInterval time.Duration
+}
+
+// New creates a client for the server at baseURL, such as "http://localhost:8080".
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		HTTP:         &http.Client{},
+		PollInterval: DefaultPollInterval,
+	}
+}
+
+// Error is an error response from the server. Code is one of the api.Code
+// constants; Retryable reports whether the same request may succeed later.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Retryable  bool
+	RequestID  string
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("server returned status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("server returned status %d (%s): %s", e.StatusCode, e.Code, e.Message)
+}
+
+// JobError is returned by Result for a job that failed or was canceled.
+type JobError struct {
+	ID     string
+	Status api.JobStatus
+	// Err describes the failure. RequestID is the ID of the request that
+	// submitted the job.
+	Err *api.Error
+}
+
+func (e *JobError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("job %s %s", e.ID, e.Status)
+	}
+	return fmt.Sprintf("job %s %s (%s): %s", e.ID, e.Status, e.Err.Code, e.Err.Message)
+}
+
+// ErrJobNotDone is returned by Result while the job is queued or running.
+var ErrJobNotDone = errors.New("job not done")
+
+// AnalyzeOptions are the optional form fields of an upload.
+type AnalyzeOptions struct {
+	// Mode is api.ModeText (the default) or api.ModeStructured.
+	Mode string
+	// Prompt is the per-page prompt in text mode.
+	Prompt string
+	// DocumentTypes are the document types to choose from in structured mode,
+	// needed when the server has no Paperless-ngx configured.
+	DocumentTypes []string
+}
+
+// Submit uploads a document for analysis and returns the queued job.
+func (c *Client) Submit(ctx context.Context, filename string, file io.Reader, opts AnalyzeOptions) (*api.JobResponse, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, fmt.Errorf("creating form file: %w", err)
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filename, err)
+	}
+	fields := map[string]string{
+		"mode":           opts.Mode,
+		"prompt":         opts.Prompt,
+		"document_types": strings.Join(opts.DocumentTypes, ","),
+	}
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		if err := mw.WriteField(name, value); err != nil {
+			return nil, fmt.Errorf("writing form field %s: %w", name, err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("closing form: %w", err)
+	}
+
+	var job api.JobResponse
+	if err := c.do(ctx, http.MethodPost, "/analyze", mw.FormDataContentType(), &body, &job, http.StatusAccepted); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Job returns a job's status and per-page progress.
+func (c *Client) Job(ctx context.Context, id string) (*api.JobInfo, error) {
+	var info api.JobInfo
+	if err := c.do(ctx, http.MethodGet, "/jobs/"+url.PathEscape(id), "", nil, &info, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Wait polls a job until it succeeds, fails or is canceled, and returns its
+// final status.
+func (c *Client) Wait(ctx context.Context, id string) (*api.JobInfo, error) {
+	interval := c.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		info, err := c.Job(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if info.Status.Done() {
+			return info, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Result decodes a succeeded job's result into v, an *api.AnalyzeResponse or
+// *api.StructuredResponse depending on the mode. It returns ErrJobNotDone while
+// the job is queued or running and a *JobError if it failed or was canceled.
+func (c *Client) Result(ctx context.Context, id string, v any) error {
+	var res api.JobResult
+	err := c.do(ctx, http.MethodGet, "/jobs/"+url.PathEscape(id)+"/result", "", nil, &res,
+		http.StatusOK, http.StatusConflict, http.StatusUnprocessableEntity)
+	if err != nil {
+		return err
+	}
+	switch res.Status {
+	case api.JobSucceeded:
+		if err := json.Unmarshal(res.Result, v); err != nil {
+			return fmt.Errorf("decoding job result: %w", err)
+		}
+		return nil
+	case api.JobFailed, api.JobCanceled:
+		return &JobError{ID: id, Status: res.Status, Err: res.Error}
+	default:
+		return ErrJobNotDone
+	}
+}
+
+// Cancel cancels a queued or running job.
+func (c *Client) Cancel(ctx context.Context, id string) (*api.JobInfo, error) {
+	var info api.JobInfo
+	if err := c.do(ctx, http.MethodPost, "/jobs/"+url.PathEscape(id)+"/cancel", "", nil, &info, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Analyze describes each page of a document in free text, using prompt when
+// set, and waits for the result.
+func (c *Client) Analyze(ctx context.Context, filename string, file io.Reader, prompt string) (*api.AnalyzeResponse, error) {
+	var res api.AnalyzeResponse
+	if err := c.analyze(ctx, filename, file, AnalyzeOptions{Mode: api.ModeText, Prompt: prompt}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// AnalyzeStructured extracts a document's metadata the way the batch does and
+// waits for the result. documentTypes may be nil when the server has
+// Paperless-ngx configured.
+func (c *Client) AnalyzeStructured(ctx context.Context, filename string, file io.Reader, documentTypes []string) (*api.StructuredResponse, error) {
+	var res api.StructuredResponse
+	if err := c.analyze(ctx, filename, file, AnalyzeOptions{Mode: api.ModeStructured, DocumentTypes: documentTypes}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// analyze submits a document, waits for the job and decodes its result into v.
+func (c *Client) analyze(ctx context.Context, filename string, file io.Reader, opts AnalyzeOptions, v any) error {
+	job, err := c.Submit(ctx, filename, file, opts)
+	if err != nil {
+		return err
+	}
+	if _, err := c.Wait(ctx, job.JobID); err != nil {
+		return err
+	}
+	return c.Result(ctx, job.JobID, v)
+}
+
+// Documents lists the Paperless-ngx documents.
+func (c *Client) Documents(ctx context.Context) ([]api.Document, error) {
+	var docs []api.Document
+	if err := c.do(ctx, http.MethodGet, "/documents", "", nil, &docs, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// Process downloads, analyzes and updates one Paperless-ngx document and
+// returns its before/after diff.
+func (c *Client) Process(ctx context.Context, id int, req api.ProcessRequest) (*api.ProcessResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("encoding request: %w", err)
+	}
+	var res api.ProcessResponse
+	path := "/documents/" + strconv.Itoa(id) + "/process"
+	if err := c.do(ctx, http.MethodPost, path, "application/json", bytes.NewReader(body), &res, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Ready returns the server's readiness. A server that is not ready is not an
+// error; check ReadyResponse.Ready.
+func (c *Client) Ready(ctx context.Context) (*api.ReadyResponse, error) {
+	var res api.ReadyResponse
+	if err := c.do(ctx, http.MethodGet, "/ready", "", nil, &res, http.StatusOK, http.StatusServiceUnavailable); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// do sends a request and decodes the JSON response into v when its status is
+// one of ok. Any other status is returned as an *Error, decoded from the error
+// envelope when the response has one.
+func (c *Client) do(ctx context.Context, method, path, contentType string, body io.Reader, v any, ok ...int) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	switch {
+	case c.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case c.Username != "":
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	for _, status := range ok {
+		if resp.StatusCode == status {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				return fmt.Errorf("decoding %s response: %w", path, err)
+			}
+			return nil
+		}
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var envelope api.ErrorResponse
+	if json.Unmarshal(data, &envelope) == nil && envelope.Error.Code != "" {
+		e := envelope.Error
+		return &Error{StatusCode: resp.StatusCode, Code: e.Code, Message: e.Message, Retryable: e.Retryable, RequestID: e.RequestID}
+	}
+	return &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/bartlettc22/paperless-llm-processor/internal/paperless"
+)
+
+// bulkChanges are the per-document changes that can be grouped into bulk edits.
+type bulkChanges struct {
+	DocumentType  *int
+	Correspondent *int
+	Tags          []int
+}
+
+// bulkUpdater groups changes that are identical across documents (same tag, same
+// correspondent, same processing markers) and sends them through Paperless's
+// bulk_edit API instead of one PATCH per document. Processing markers are only
+// applied to a document once all of its other bulk changes have succeeded, so a
+// failed flush leaves the document to be picked up again on the next run.
+type bulkUpdater struct {
+	client  *paperless.Client
+	maxDocs int
+	// markers are custom field values (by field ID) set on every flushed document.
+	markers map[int]interface{}
+	// failed, if set, is called for each document whose bulk edits failed.
+	failed func(docID int, err error)
+	// succeeded, if set, is called for each document once its bulk edits and
+	// processing markers have been applied.
+	succeeded func(docID int)
+
+	docs           []int
+	documentTypes  map[int][]int
+	correspondents map[int][]int
+	tags           map[int][]int
+}
+
+func newBulkUpdater(client *paperless.Client, maxDocs int, markers map[int]interface{}) *bulkUpdater {
+	b := &bulkUpdater{client: client, maxDocs: maxDocs, markers: markers}
+	b.reset()
+	return b
+}
+
+func (b *bulkUpdater) reset() {
+	b.docs = nil
+	b.documentTypes = make(map[int][]int)
+	b.correspondents = make(map[int][]int)
+	b.tags = make(map[int][]int)
+}
+
+// queue adds a document's changes and flushes once maxDocs documents are pending.
+func (b *bulkUpdater) queue(ctx context.Context, docID int, changes bulkChanges) {
+	b.docs = append(b.docs, docID)
+	if changes.DocumentType != nil {
+		b.documentTypes[*changes.DocumentType] = append(b.documentTypes[*changes.DocumentType], docID)
+	}
+	if changes.Correspondent != nil {
+		b.correspondents[*changes.Correspondent] = append(b.correspondents[*changes.Correspondent], docID)
+	}
+	for _, tagID := range changes.Tags {
+		b.tags[tagID] = append(b.tags[tagID], docID)
+	}
+
+	if len(b.docs) >= b.maxDocs {
+		b.flush(ctx)
+	}
+}
+
+// flush sends all pending bulk edits.
+func (b *bulkUpdater) flush(ctx context.Context) {
+	if len(b.docs) == 0 {
+		return
+	}
+	defer b.reset()
+
+	failed := make(map[int]bool)
+	fail := func(docIDs []int, err error) {
+		for _, docID := range docIDs {
+			if !failed[docID] && b.failed != nil {
+				b.failed(docID, err)
+			}
+			failed[docID] = true
+		}
+	}
+	apply := func(what string, groups map[int][]int, edit func(context.Context, []int, int) error) {
+		for id, docIDs := range groups {
+			if err := edit(ctx, docIDs, id); err != nil {
+				slog.ErrorContext(ctx, "bulk edit failed", "edit", what, "id", id, "documents", len(docIDs), "error", err)
+				fail(docIDs, fmt.Errorf("bulk %s %d: %w", what, id, err))
+			}
+		}
+	}
+	apply("set document type", b.documentTypes, b.client.BulkSetDocumentType)
+	apply("set correspondent", b.correspondents, b.client.BulkSetCorrespondent)
+	apply("add tag", b.tags, b.client.BulkAddTag)
+
+	done := slices.DeleteFunc(slices.Clone(b.docs), func(id int) bool { return failed[id] })
+	if err := b.client.BulkModifyCustomFields(ctx, done, b.markers, nil); err != nil {
+		slog.ErrorContext(ctx, "bulk setting processing markers failed", "documents", len(done), "error", err)
+		fail(done, fmt.Errorf("bulk setting processing markers: %w", err))
+		return
+	}
+	if b.succeeded != nil {
+		for _, docID := range done {
+			b.succeeded(docID)
+		}
+	}
+	slog.InfoContext(ctx, "bulk updated documents", "documents", len(done), "document_types", len(b.documentTypes),
+		"correspondents", len(b.correspondents), "tags", len(b.tags), "failed", len(failed))
+}
@@ -2,20 +2,31 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/bartlettc22/paperless-llm-processor/internal/converter"
+	"github.com/bartlettc22/paperless-llm-processor/internal/logging"
+	"github.com/bartlettc22/paperless-llm-processor/internal/metrics"
 	"github.com/bartlettc22/paperless-llm-processor/internal/ollama"
 	"github.com/bartlettc22/paperless-llm-processor/internal/paperless"
+	"github.com/bartlettc22/paperless-llm-processor/internal/pipeline"
 )
 
 func main() {
+	// LOG_FORMAT is text (default) or json; LOG_LEVEL is debug, info (default), warn or error
+	if err := logging.Setup(os.Stderr, os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL")); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	paperlessURL := os.Getenv("PAPERLESS_URL")
 	paperlessToken := os.Getenv("PAPERLESS_TOKEN")
 	ollamaURL := os.Getenv("OLLAMA_URL")
@@ -34,21 +45,16 @@ func main() {
 	}
 
 	if paperlessURL == "" || paperlessToken == "" {
-		log.Fatal("PAPERLESS_URL and PAPERLESS_TOKEN must be set")
+		logging.Fatal("PAPERLESS_URL and PAPERLESS_TOKEN must be set")
 	}
 
 	// UPDATE_FIELDS controls which document fields to update (comma-separated).
-	// Valid values: title, document_type, document_date, summary, content, correspondent, tags
+	// Valid values: title, document_type, document_date, summary, content, correspondent, tags, storage_path, permissions
 	// If empty or unset, all fields are updated.
 	updateFieldsEnv := os.Getenv("UPDATE_FIELDS")
-	updateFields := map[string]bool{
-		"title":         true,
-		"document_type": true,
-		"document_date": true,
-		"summary":       true,
-		"content":       true,
-		"correspondent": true,
-		"tags":          true,
+	updateFields := make(map[string]bool, len(pipeline.Fields))
+	for _, f := range pipeline.Fields {
+		updateFields[f] = true
 	}
 	if updateFieldsEnv != "" {
 		updateFields = make(map[string]bool)
@@ -58,281 +64,358 @@ func main() {
 				updateFields[f] = true
 			}
 		}
-		log.Printf("UPDATE_FIELDS: only updating %v", updateFieldsEnv)
+		slog.Info("UPDATE_FIELDS: only updating selected fields", "fields", updateFieldsEnv)
 	}
 
-	const processID = 5
-	const fieldName = "llm-process-id"
+	rasterOpts, err := rasterOptionsFromEnv(ollamaModel)
+	if err != nil {
+		logging.Fatal("invalid rasterization settings", "error", err)
+	}
+	slog.Info("rasterization", "dpi", rasterOpts.DPI, "gray", rasterOpts.Gray, "format", rasterOpts.Format,
+		"quality", rasterOpts.Quality, "max_dimension", rasterOpts.MaxDimension)
 
-	pClient := paperless.NewClient(paperlessURL, paperlessToken)
-	oClient := ollama.NewClient(ollamaURL, ollamaModel)
-	ctx := context.Background()
+	const processID = pipeline.ProcessID
+	const fieldName = pipeline.ProcessField
 
-	cf, err := pClient.EnsureCustomField(ctx, fieldName, "integer")
+	transportOpts, err := paperless.TransportOptionsFromEnv()
 	if err != nil {
-		log.Fatalf("Failed to ensure custom field '%s': %v", fieldName, err)
+		logging.Fatal("invalid Paperless client settings", "error", err)
+	}
+	pClient := paperless.NewClientWithOptions(paperlessURL, paperlessToken, transportOpts)
+	if v := os.Getenv("PAPERLESS_PAGE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			logging.Fatal("invalid PAPERLESS_PAGE_SIZE", "value", v)
+		}
+		pClient.PageSize = n
+	}
+	oClient := ollama.NewClient(ollamaURL, ollamaModel)
+	runID := logging.NewRunID()
+	ctx := logging.With(context.Background(), logging.KeyRunID, runID, logging.KeyModel, ollamaModel)
+	report := newRunReport(runID, ollamaModel)
+
+	// METRICS_ADDR (e.g. :9090) serves Prometheus metrics on /metrics while the batch runs,
+	// and for METRICS_LINGER (e.g. 1m) afterwards so the final values can be scraped
+	var metricsLinger time.Duration
+	if addr := os.Getenv("METRICS_ADDR"); addr != "" {
+		if v := os.Getenv("METRICS_LINGER"); v != "" {
+			metricsLinger, err = time.ParseDuration(v)
+			if err != nil || metricsLinger < 0 {
+				logging.Fatal("invalid METRICS_LINGER", "value", v)
+			}
+		}
+		metrics.Serve(addr)
+		slog.InfoContext(ctx, "serving metrics", "addr", addr)
 	}
-	log.Printf("Using custom field '%s' (id=%d), processID=%d", fieldName, cf.ID, processID)
 
-	const summaryFieldName = "llm-summary"
-	summaryCF, err := pClient.EnsureCustomField(ctx, summaryFieldName, "longtext")
+	server, err := pClient.Detect(ctx)
 	if err != nil {
-		log.Fatalf("Failed to ensure custom field '%s': %v", summaryFieldName, err)
+		logging.Fatal("failed to detect Paperless-ngx version", "error", err)
+	}
+	slog.InfoContext(ctx, "Paperless-ngx detected", "version", server.Version, "api_version", server.APIVersion, "using_api_version", pClient.APIVersion)
+	if !server.Has(paperless.CapCustomFields) {
+		logging.Fatal("Paperless-ngx does not support custom fields (2.0 or newer required)", "version", server.Version)
+	}
+	if !server.Has(paperless.CapCustomFieldQuery) {
+		slog.WarnContext(ctx, "Paperless-ngx has no custom_field_query; unprocessed documents are filtered client-side", "version", server.Version)
+	}
+
+	// CUSTOM_FIELD_MIGRATE replaces processor fields that exist with the wrong data
+	// type instead of refusing to start (see EnsureCustomFieldSpec).
+	migrateFields, _ := strconv.ParseBool(os.Getenv("CUSTOM_FIELD_MIGRATE"))
+	ensureField := func(name, dataType string) (paperless.CustomField, error) {
+		return pClient.EnsureCustomFieldSpec(ctx, paperless.CustomFieldSpec{Name: name, DataType: dataType}, migrateFields)
 	}
-	log.Printf("Using custom field '%s' (id=%d)", summaryFieldName, summaryCF.ID)
 
-	const modelFieldName = "llm-model"
-	modelCF, err := pClient.EnsureCustomField(ctx, modelFieldName, "string")
+	cf, err := ensureField(fieldName, paperless.DataTypeInteger)
 	if err != nil {
-		log.Fatalf("Failed to ensure custom field '%s': %v", modelFieldName, err)
+		logging.Fatal("failed to ensure custom field", "field", fieldName, "error", err)
 	}
-	log.Printf("Using custom field '%s' (id=%d)", modelFieldName, modelCF.ID)
+	slog.InfoContext(ctx, "using custom field", "field", fieldName, "id", cf.ID, "process_id", processID)
 
-	const skipFieldName = "llm-skip"
-	_, err = pClient.EnsureCustomField(ctx, skipFieldName, "boolean")
+	const summaryFieldName = pipeline.SummaryField
+	summaryCF, err := ensureField(summaryFieldName, paperless.DataTypeLongText)
 	if err != nil {
-		log.Fatalf("Failed to ensure custom field '%s': %v", skipFieldName, err)
+		logging.Fatal("failed to ensure custom field", "field", summaryFieldName, "error", err)
 	}
-	log.Printf("Using custom field '%s' for skip filtering", skipFieldName)
+	slog.InfoContext(ctx, "using custom field", "field", summaryFieldName, "id", summaryCF.ID)
 
-	docTypes, err := pClient.ListDocumentTypes(ctx)
+	const modelFieldName = pipeline.ModelField
+	modelCF, err := ensureField(modelFieldName, paperless.DataTypeString)
 	if err != nil {
-		log.Fatalf("Failed to list document types: %v", err)
+		logging.Fatal("failed to ensure custom field", "field", modelFieldName, "error", err)
 	}
-	docTypeNames := make([]string, len(docTypes))
-	docTypeIDByName := make(map[string]int, len(docTypes))
-	for i, dt := range docTypes {
-		docTypeNames[i] = dt.Name
-		docTypeIDByName[dt.Name] = dt.ID
+	slog.InfoContext(ctx, "using custom field", "field", modelFieldName, "id", modelCF.ID)
+
+	const skipFieldName = pipeline.SkipField
+	_, err = ensureField(skipFieldName, paperless.DataTypeBoolean)
+	if err != nil {
+		logging.Fatal("failed to ensure custom field", "field", skipFieldName, "error", err)
 	}
-	log.Printf("Loaded %d document types: %v", len(docTypeNames), docTypeNames)
+	slog.InfoContext(ctx, "using custom field for skip filtering", "field", skipFieldName)
 
-	// Load existing correspondents for lookup/creation
-	corrList, err := pClient.ListCorrespondents(ctx)
+	catalog, err := pipeline.LoadCatalog(ctx, pClient)
 	if err != nil {
-		log.Fatalf("Failed to list correspondents: %v", err)
+		logging.Fatal("failed to load Paperless-ngx metadata", "error", err)
 	}
-	corrIDByName := make(map[string]int, len(corrList))
-	for _, c := range corrList {
-		corrIDByName[c.Name] = c.ID
+	docTypeNames := catalog.DocumentTypeNames
+	slog.InfoContext(ctx, "loaded Paperless-ngx metadata", "document_types", docTypeNames,
+		"correspondents", len(catalog.Correspondents), "tags", len(catalog.Tags), "storage_paths", len(catalog.StoragePaths))
+
+	// STORAGE_PATH_MODE chooses a storage path for each document: "rules" maps the
+	// extracted document type/correspondent through the STORAGE_PATH_RULES JSON file,
+	// "llm" lets the model pick from the storage path names. Unset leaves paths alone.
+	// PERMISSION_RULES names a JSON rule file mapping extracted content to a document
+	// owner and view/change permissions. Unset leaves ownership alone.
+	storagePathMode, storagePathRules := os.Getenv("STORAGE_PATH_MODE"), os.Getenv("STORAGE_PATH_RULES")
+	if storagePathMode == pipeline.StoragePathRules && storagePathRules == "" {
+		logging.Fatal("STORAGE_PATH_RULES is required when STORAGE_PATH_MODE=rules")
 	}
-	log.Printf("Loaded %d correspondents", len(corrList))
-
-	// Load existing tags for lookup/creation
-	tagList, err := pClient.ListTags(ctx)
+	rules, err := pipeline.LoadRules(ctx, pClient, storagePathMode, storagePathRules, os.Getenv("PERMISSION_RULES"))
 	if err != nil {
-		log.Fatalf("Failed to list tags: %v", err)
+		logging.Fatal("failed to load storage path and permission rules", "error", err)
 	}
-	tagIDByName := make(map[string]int, len(tagList))
-	for _, t := range tagList {
-		tagIDByName[t.Name] = t.ID
+	slog.InfoContext(ctx, "loaded rules", "storage_path_mode", rules.StoragePathMode,
+		"storage_path_rules", len(rules.StoragePaths), "permission_rules", len(rules.Permissions))
+	choices := rules.Choices(catalog)
+
+	// SUMMARY_NOTE also writes the summary as a document note, replacing the
+	// processor's previous note but leaving notes written by people alone.
+	summaryNote, _ := strconv.ParseBool(os.Getenv("SUMMARY_NOTE"))
+	if summaryNote && !server.Has(paperless.CapNotes) {
+		logging.Fatal("SUMMARY_NOTE requires document notes, which this Paperless-ngx does not support", "version", server.Version)
 	}
-	log.Printf("Loaded %d tags", len(tagList))
 
-	docs, err := pClient.ListUnprocessedDocuments(ctx, fieldName, processID, skipFieldName)
-	if err != nil {
-		log.Fatalf("Failed to list unprocessed documents: %v", err)
+	proc := &pipeline.Processor{
+		Paperless:      pClient,
+		Catalog:        catalog,
+		SummaryFieldID: summaryCF.ID,
+		Model:          ollamaModel,
+		SummaryNote:    summaryNote,
+		Created:        report.created,
 	}
 
-	log.Printf("Found %d unprocessed documents", len(docs))
+	succeeded := metrics.DocumentsProcessed.WithLabelValues(ollamaModel, metrics.DocumentSucceeded)
+	failed := metrics.DocumentsProcessed.WithLabelValues(ollamaModel, metrics.DocumentFailed)
 
-	for _, doc := range docs {
-		log.Printf("Processing document %d: %s", doc.ID, doc.Title)
+	// BULK_EDIT groups document type, correspondent, tag and processing marker changes
+	// into bulk_edit requests covering up to BULK_EDIT_SIZE documents (default 50).
+	var bulk *bulkUpdater
+	if enabled, _ := strconv.ParseBool(os.Getenv("BULK_EDIT")); enabled {
+		if !server.Has(paperless.CapBulkCustomFieldValues) {
+			logging.Fatal("BULK_EDIT requires setting custom field values in bulk (Paperless-ngx 2.15 or newer)", "version", server.Version)
+		}
+		size := 50
+		if v := os.Getenv("BULK_EDIT_SIZE"); v != "" {
+			size, err = strconv.Atoi(v)
+			if err != nil || size < 1 {
+				logging.Fatal("invalid BULK_EDIT_SIZE", "value", v)
+			}
+		}
+		bulk = newBulkUpdater(pClient, size, map[int]interface{}{cf.ID: processID, modelCF.ID: ollamaModel})
+		bulk.succeeded = func(docID int) {
+			report.bulkProcessed(docID)
+			succeeded.Inc()
+		}
+		bulk.failed = func(docID int, err error) {
+			report.bulkFailed(docID, err)
+			failed.Inc()
+		}
+		slog.InfoContext(ctx, "bulk edit enabled", "batch_size", size)
+	}
 
-		data, err := pClient.DownloadDocument(ctx, doc.ID)
+	sel, err := selectionFromEnv(catalog.Tags, catalog.Correspondents, catalog.DocumentTypes)
+	if err != nil {
+		logging.Fatal("invalid document selection", "error", err)
+	}
+	if sel.force {
+		slog.InfoContext(ctx, "selecting documents (FORCE: ignoring processing and skip fields)", "process_field", fieldName, "skip_field", skipFieldName)
+	} else {
+		slog.InfoContext(ctx, "selecting unprocessed documents", "process_field", fieldName, "process_id", processID)
+	}
+
+	selected := 0
+	for doc, err := range selectDocuments(ctx, pClient, sel, fieldName, processID, skipFieldName) {
 		if err != nil {
-			log.Printf("  ERROR downloading document %d: %v", doc.ID, err)
-			continue
+			// Stop selecting but still flush pending bulk edits below
+			slog.ErrorContext(ctx, "listing documents failed", "error", err)
+			report.Error = err.Error()
+			break
 		}
+		selected++
+		ctx := logging.With(ctx, logging.KeyDocumentID, doc.ID)
+		slog.InfoContext(ctx, "processing document", "title", doc.Title)
+		rep := report.document(doc.ID, doc.Title)
 
-		images, err := fileToBase64Images(data)
+		start := time.Now()
+		data, err := pClient.DownloadDocument(ctx, doc.ID)
 		if err != nil {
-			log.Printf("  ERROR converting document %d: %v", doc.ID, err)
+			slog.ErrorContext(ctx, "downloading document failed", "error", err)
+			rep.failed(metrics.StageDownload, err)
+			failed.Inc()
 			continue
 		}
+		metrics.ObserveStage(ollamaModel, metrics.StageDownload, start)
 
-		log.Printf("  Analyzing %d page(s) with %s...", len(images), ollamaModel)
-
-		var merged ollama.DocumentAnalysis
-		var summaries []string
-		var transcriptions []string
-		seenTags := make(map[string]bool)
-		analyzeErr := false
-
-		for i, img := range images {
-			log.Printf("  Analyzing page %d/%d...", i+1, len(images))
-			pageResult, err := oClient.AnalyzeStructured(img, docTypeNames)
-			if err != nil {
-				log.Printf("  ERROR analyzing document %d page %d: %v", doc.ID, i+1, err)
-				analyzeErr = true
-				break
-			}
-
-			if pageResult.Summary != "" {
-				summaries = append(summaries, pageResult.Summary)
-			}
-			if pageResult.Transcription != "" {
-				transcriptions = append(transcriptions, pageResult.Transcription)
-			}
-
-			// Use metadata from first page that provides it
-			if merged.FileName == "" && pageResult.FileName != "" {
-				merged.FileName = pageResult.FileName
-			}
-			if merged.DocumentType == "" && pageResult.DocumentType != "" {
-				merged.DocumentType = pageResult.DocumentType
-			}
-			if merged.DocumentDate == "" && pageResult.DocumentDate != "" {
-				merged.DocumentDate = pageResult.DocumentDate
-			}
-			if merged.Correspondent == "" && pageResult.Correspondent != "" {
-				merged.Correspondent = pageResult.Correspondent
-			}
-
-			// Merge tags across pages (deduplicated)
-			for _, t := range pageResult.Tags {
-				if t != "" && !seenTags[t] {
-					seenTags[t] = true
-					merged.Tags = append(merged.Tags, t)
-				}
-			}
-
+		start = time.Now()
+		pages, err := converter.FileToPages(data, "debug-images", rasterOpts)
+		if errors.Is(err, converter.ErrUnsupportedType) {
+			slog.WarnContext(ctx, "skipping document", "error", err)
+			rep.skipped(metrics.StageRasterize, err)
+			continue
 		}
-
-		if analyzeErr {
+		if err != nil {
+			slog.ErrorContext(ctx, "converting document failed", "error", err)
+			rep.failed(metrics.StageRasterize, err)
+			failed.Inc()
 			continue
 		}
+		rep.Pages = len(pages)
+		metrics.ObserveStage(ollamaModel, metrics.StageRasterize, start)
 
-		merged.Summary = strings.Join(summaries, "\n\n")
-		merged.Transcription = strings.Join(transcriptions, "\n\n")
+		slog.InfoContext(ctx, "analyzing document", "pages", len(pages))
 
-		result := map[string]interface{}{
-			"document_id":    doc.ID,
-			"document_title": doc.Title,
-			"analysis":       merged,
-		}
-		enc := json.NewEncoder(os.Stdout)
-		enc.SetIndent("", "  ")
-		enc.Encode(result)
-		fmt.Println()
-
-		update := paperless.DocumentUpdate{
-			CustomFields: []paperless.CustomFieldValue{
-				{Field: cf.ID, Value: processID},
-				{Field: modelCF.ID, Value: ollamaModel},
-			},
+		merged, err := pipeline.AnalyzePages(ctx, oClient, pages, choices, rep)
+		if err != nil {
+			slog.ErrorContext(ctx, "analyzing document failed", "error", err)
+			rep.failed(metrics.StageLLM, err)
+			failed.Inc()
+			continue
 		}
 
-		if updateFields["title"] {
-			update.Title = &merged.FileName
+		slog.DebugContext(ctx, "analysis", "title", doc.Title, "analysis", merged)
+
+		start = time.Now()
+		update, skipped := proc.BuildUpdate(ctx, pipeline.Plan(ctx, merged, updateFields, catalog, rules))
+		rep.SkippedFields = skipped
+		var changes bulkChanges
+		if bulk != nil {
+			// Bulk mode adds tags to the existing ones; a PATCH replaces them.
+			// Title, content, date, summary, storage path and permissions differ
+			// per document and still go in the PATCH below. Its custom fields
+			// keep the document's current processing markers, which are only
+			// updated by the flush once the bulk edits have succeeded.
+			changes = bulkChanges{DocumentType: update.DocumentType, Correspondent: update.Correspondent, Tags: update.Tags}
+			update.DocumentType, update.Correspondent, update.Tags = nil, nil, nil
+		} else {
+			update.CustomFields = append(update.CustomFields,
+				paperless.CustomFieldValue{Field: cf.ID, Value: processID},
+				paperless.CustomFieldValue{Field: modelCF.ID, Value: ollamaModel},
+			)
 		}
-
-		if updateFields["summary"] {
-			update.CustomFields = append(update.CustomFields, paperless.CustomFieldValue{Field: summaryCF.ID, Value: merged.Summary})
+		if len(update.CustomFields) > 0 {
+			update.CustomFields = paperless.MergeCustomFields(doc.CustomFields, update.CustomFields)
 		}
 
-		if updateFields["content"] && merged.Transcription != "" {
-			update.Content = &merged.Transcription
+		if err := proc.Update(ctx, doc.ID, update, merged.Summary); err != nil {
+			slog.ErrorContext(ctx, "updating document failed", "error", err)
+			rep.failed(metrics.StageUpdate, err)
+			failed.Inc()
+			continue
 		}
-
-		if updateFields["document_type"] {
-			if dtID, ok := docTypeIDByName[merged.DocumentType]; ok {
-				update.DocumentType = &dtID
-			} else {
-				log.Printf("  WARNING: unknown document type '%s', skipping type update", merged.DocumentType)
-			}
+		metrics.ObserveStage(ollamaModel, metrics.StageUpdate, start)
+
+		if bulk != nil {
+			// Counted as succeeded or failed once the bulk edits are flushed
+			rep.queued()
+			bulk.queue(ctx, doc.ID, changes)
+			slog.InfoContext(ctx, "updated document (type, correspondent, tags and processing marker queued for bulk edit)",
+				"title", merged.FileName, "document_date", merged.DocumentDate)
+			continue
 		}
+		succeeded.Inc()
+		rep.processed()
+		slog.InfoContext(ctx, "updated document", "title", merged.FileName, "document_type", merged.DocumentType,
+			"document_date", merged.DocumentDate, "process_id", processID)
+	}
 
-		if updateFields["document_date"] && merged.DocumentDate != "" {
-			update.Created = &merged.DocumentDate
+	if bulk != nil {
+		bulk.flush(ctx)
+	}
+	report.finish()
+	writeReport(ctx, report)
+	slog.InfoContext(ctx, "done", "selected", selected, "processed", report.Processed, "skipped", report.Skipped, "failed", report.Failed)
+
+	if metricsLinger > 0 {
+		// SIGINT or SIGTERM ends the wait early
+		sigCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		slog.InfoContext(ctx, "serving final metrics before exiting", "linger", metricsLinger)
+		select {
+		case <-time.After(metricsLinger):
+		case <-sigCtx.Done():
 		}
+	}
+}
 
-		if updateFields["correspondent"] && merged.Correspondent != "" {
-			corrID, err := pClient.EnsureCorrespondent(ctx, merged.Correspondent, corrIDByName)
-			if err != nil {
-				log.Printf("  WARNING: failed to ensure correspondent '%s': %v", merged.Correspondent, err)
-			} else {
-				update.Correspondent = &corrID
-				log.Printf("  Correspondent: %s", merged.Correspondent)
-			}
-		}
+// rasterOptionsFromEnv starts from the model's rasterization profile and applies
+// any RASTER_* environment overrides:
+// RASTER_DPI, RASTER_COLOR (true/false), RASTER_FORMAT (jpeg/png), RASTER_QUALITY, RASTER_MAX_DIMENSION,
+// plus TILE_GRID (e.g. 2x3), TILE_OVERLAP (fraction, default 0.1) and TILE_MAX_DIMENSION for tiling,
+// and PREPROCESS (e.g. crop,deskew,contrast) for image cleanup.
+func rasterOptionsFromEnv(model string) (converter.Options, error) {
+	opts := converter.OptionsForModel(model)
 
-		if updateFields["tags"] && len(merged.Tags) > 0 {
-			var tagIDs []int
-			for _, name := range merged.Tags {
-				tagID, err := pClient.EnsureTag(ctx, name, tagIDByName)
-				if err != nil {
-					log.Printf("  WARNING: failed to ensure tag '%s': %v", name, err)
-					continue
-				}
-				tagIDs = append(tagIDs, tagID)
-			}
-			if len(tagIDs) > 0 {
-				update.Tags = tagIDs
-				log.Printf("  Tags: %v", merged.Tags)
-			}
+	if v := os.Getenv("RASTER_DPI"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("RASTER_DPI: %w", err)
 		}
-
-		if err := pClient.UpdateDocument(ctx, doc.ID, update); err != nil {
-			log.Printf("  ERROR updating document %d: %v", doc.ID, err)
-			continue
+		opts.DPI = n
+	}
+	if v := os.Getenv("RASTER_COLOR"); v != "" {
+		color, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("RASTER_COLOR: %w", err)
 		}
-		log.Printf("  Updated document %d: title=%s, type=%s, date=%s, %s=%d",
-			doc.ID, merged.FileName, merged.DocumentType, merged.DocumentDate, fieldName, processID)
+		opts.Gray = !color
 	}
-}
-
-// fileToBase64Images detects the file type and converts to base64-encoded images.
-func fileToBase64Images(data []byte) ([]string, error) {
-	contentType := http.DetectContentType(data)
-
-	switch {
-	case strings.HasPrefix(contentType, "application/pdf"):
-		tmpFile, err := os.CreateTemp("", "doc-*.pdf")
+	if v := os.Getenv("RASTER_FORMAT"); v != "" {
+		opts.Format = strings.ToLower(v)
+	}
+	if v := os.Getenv("RASTER_QUALITY"); v != "" {
+		n, err := strconv.Atoi(v)
 		if err != nil {
-			return nil, fmt.Errorf("creating temp file: %w", err)
+			return opts, fmt.Errorf("RASTER_QUALITY: %w", err)
 		}
-		defer os.Remove(tmpFile.Name())
-		if _, err := tmpFile.Write(data); err != nil {
-			tmpFile.Close()
-			return nil, fmt.Errorf("writing temp file: %w", err)
+		opts.Quality = n
+	}
+	if v := os.Getenv("RASTER_MAX_DIMENSION"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("RASTER_MAX_DIMENSION: %w", err)
 		}
-		tmpFile.Close()
-		return converter.PDFToBase64Images(tmpFile.Name(), "debug-images")
-
-	case strings.HasPrefix(contentType, "image/"):
-		tmpFile, err := os.CreateTemp("", "doc-*"+extForContentType(contentType))
+		opts.MaxDimension = n
+	}
+	if v := os.Getenv("TILE_GRID"); v != "" {
+		cols, rows, err := converter.ParseGrid(v)
 		if err != nil {
-			return nil, fmt.Errorf("creating temp file: %w", err)
+			return opts, fmt.Errorf("TILE_GRID: %w", err)
 		}
-		defer os.Remove(tmpFile.Name())
-		if _, err := tmpFile.Write(data); err != nil {
-			tmpFile.Close()
-			return nil, fmt.Errorf("writing temp file: %w", err)
+		opts.Tiles.Cols, opts.Tiles.Rows = cols, rows
+		opts.Tiles.Overlap = 0.1
+	}
+	if v := os.Getenv("TILE_OVERLAP"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return opts, fmt.Errorf("TILE_OVERLAP: %w", err)
 		}
-		tmpFile.Close()
-		img, err := converter.ImageToBase64(tmpFile.Name())
+		opts.Tiles.Overlap = f
+	}
+	if v := os.Getenv("TILE_MAX_DIMENSION"); v != "" {
+		n, err := strconv.Atoi(v)
 		if err != nil {
-			return nil, err
+			return opts, fmt.Errorf("TILE_MAX_DIMENSION: %w", err)
 		}
-		return []string{img}, nil
-
-	default:
-		return nil, fmt.Errorf("unsupported content type: %s", contentType)
+		opts.Tiles.MaxDimension = n
 	}
-}
-
-func extForContentType(ct string) string {
-	switch {
-	case strings.Contains(ct, "png"):
-		return ".png"
-	case strings.Contains(ct, "jpeg"):
-		return ".jpg"
-	case strings.Contains(ct, "gif"):
-		return ".gif"
-	case strings.Contains(ct, "webp"):
-		return ".webp"
-	default:
-		return filepath.Ext(ct)
+	if v := os.Getenv("PREPROCESS"); v != "" {
+		p, err := converter.ParsePreprocess(v)
+		if err != nil {
+			return opts, fmt.Errorf("PREPROCESS: %w", err)
+		}
+		opts.Preprocess = p
 	}
+
+	return opts, opts.Validate()
 }
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bartlettc22/paperless-llm-processor/internal/metrics"
+	"github.com/bartlettc22/paperless-llm-processor/internal/pipeline"
+)
+
+// Document statuses in the run report.
+const (
+	statusProcessed = "processed"
+	statusSkipped   = "skipped"
+	statusFailed    = "failed"
+	// statusQueued is a document waiting for its bulk edits. Every queued
+	// document is processed or failed by the final flush.
+	statusQueued = "queued"
+)
+
+// runReport summarizes a batch run: what happened to each selected document,
+// timings, and the correspondents and tags the run created.
+type runReport struct {
+	RunID           string    `json:"run_id"`
+	Model           string    `json:"model"`
+	StartedAt       time.Time `json:"started_at"`
+	FinishedAt      time.Time `json:"finished_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Processed       int       `json:"processed"`
+	Skipped         int       `json:"skipped"`
+	Failed          int       `json:"failed"`
+	// Error is set when document selection stopped early.
+	Error                 string            `json:"error,omitempty"`
+	Documents             []*documentReport `json:"documents"`
+	CreatedCorrespondents []string          `json:"created_correspondents"`
+	CreatedTags           []string          `json:"created_tags"`
+}
+
+// documentReport is one document's outcome. It implements pipeline.Progress to
+// time each page.
+type documentReport struct {
+	ID     int    `json:"id"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+	// Stage is where a failed or skipped document stopped: download, rasterize, llm or update.
+	Stage           string                  `json:"stage,omitempty"`
+	Error           string                  `json:"error,omitempty"`
+	Pages           int                     `json:"pages"`
+	DurationSeconds float64                 `json:"duration_seconds"`
+	PageSeconds     []float64               `json:"page_seconds,omitempty"`
+	SkippedFields   []pipeline.SkippedField `json:"skipped_fields,omitempty"`
+
+	start     time.Time
+	pageStart time.Time
+}
+
+func newRunReport(runID, model string) *runReport {
+	return &runReport{
+		RunID:                 runID,
+		Model:                 model,
+		StartedAt:             time.Now(),
+		Documents:             []*documentReport{},
+		CreatedCorrespondents: []string{},
+		CreatedTags:           []string{},
+	}
+}
+
+// document starts the report of a selected document.
+func (r *runReport) document(id int, title string) *documentReport {
+	d := &documentReport{ID: id, Title: title, start: time.Now()}
+	r.Documents = append(r.Documents, d)
+	return d
+}
+
+// created records a correspondent or tag created by the run. It matches
+// pipeline.Processor.Created.
+func (r *runReport) created(kind, name string) {
+	switch kind {
+	case "correspondent":
+		r.CreatedCorrespondents = append(r.CreatedCorrespondents, name)
+	case "tag":
+		r.CreatedTags = append(r.CreatedTags, name)
+	}
+}
+
+// bulkProcessed marks a queued document whose bulk edits succeeded.
+func (r *runReport) bulkProcessed(docID int) {
+	for _, d := range r.Documents {
+		if d.ID == docID && d.Status == statusQueued {
+			d.Status = statusProcessed
+		}
+	}
+}
+
+// bulkFailed marks a queued document whose bulk edits failed.
+func (r *runReport) bulkFailed(docID int, err error) {
+	for _, d := range r.Documents {
+		if d.ID == docID && d.Status == statusQueued {
+			d.Status, d.Stage, d.Error = statusFailed, metrics.StageUpdate, err.Error()
+		}
+	}
+}
+
+// finish stamps the end time and counts the documents by status.
+func (r *runReport) finish() {
+	r.FinishedAt = time.Now()
+	r.DurationSeconds = seconds(r.FinishedAt.Sub(r.StartedAt))
+	r.Processed, r.Skipped, r.Failed = 0, 0, 0
+	for _, d := range r.Documents {
+		switch d.Status {
+		case statusProcessed:
+			r.Processed++
+		case statusSkipped:
+			r.Skipped++
+		case statusFailed:
+			r.Failed++
+		}
+	}
+}
+
+func (d *documentReport) StartPage(i int) {
+	d.pageStart = time.Now()
+}
+
+func (d *documentReport) FinishPage(i int, err error) {
+	d.PageSeconds = append(d.PageSeconds, seconds(time.Since(d.pageStart)))
+}
+
+func (d *documentReport) processed() {
+	d.end(statusProcessed, "", nil)
+}
+
+// queued records a document updated except for its bulk edits, which decide
+// whether it is processed or failed.
+func (d *documentReport) queued() {
+	d.end(statusQueued, "", nil)
+}
+
+func (d *documentReport) skipped(stage string, err error) {
+	d.end(statusSkipped, stage, err)
+}
+
+func (d *documentReport) failed(stage string, err error) {
+	d.end(statusFailed, stage, err)
+}
+
+func (d *documentReport) end(status, stage string, err error) {
+	d.Status, d.Stage = status, stage
+	if err != nil {
+		d.Error = err.Error()
+	}
+	d.DurationSeconds = seconds(time.Since(d.start))
+}
+
+// seconds rounds a duration to milliseconds, in seconds.
+func seconds(d time.Duration) float64 {
+	return d.Round(time.Millisecond).Seconds()
+}
+
+// markdown renders the report for humans.
+func (r *runReport) markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Run report %s\n\n", r.RunID)
+	fmt.Fprintf(&b, "- Model: `%s`\n", r.Model)
+	fmt.Fprintf(&b, "- Started: %s\n", r.StartedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Duration: %s\n", time.Duration(r.DurationSeconds*float64(time.Second)).Round(time.Second))
+	fmt.Fprintf(&b, "- Processed: %d, skipped: %d, failed: %d\n", r.Processed, r.Skipped, r.Failed)
+	if r.Error != "" {
+		fmt.Fprintf(&b, "- Selection stopped early: %s\n", r.Error)
+	}
+
+	if len(r.Documents) > 0 {
+		b.WriteString("\n## Documents\n\n")
+		b.WriteString("| ID | Title | Status | Pages | Seconds | Details |\n")
+		b.WriteString("|---|---|---|---|---|---|\n")
+		for _, d := range r.Documents {
+			var details []string
+			if d.Error != "" {
+				details = append(details, fmt.Sprintf("%s: %s", d.Stage, d.Error))
+			}
+			for _, s := range d.SkippedFields {
+				details = append(details, fmt.Sprintf("skipped %s %q: %s", s.Field, s.Value, s.Reason))
+			}
+			fmt.Fprintf(&b, "| %d | %s | %s | %d | %.1f | %s |\n",
+				d.ID, markdownCell(d.Title), d.Status, d.Pages, d.DurationSeconds, markdownCell(strings.Join(details, "; ")))
+		}
+	}
+
+	if len(r.CreatedCorrespondents) > 0 || len(r.CreatedTags) > 0 {
+		b.WriteString("\n## Created\n\n")
+		if len(r.CreatedCorrespondents) > 0 {
+			fmt.Fprintf(&b, "- Correspondents: %s\n", strings.Join(r.CreatedCorrespondents, ", "))
+		}
+		if len(r.CreatedTags) > 0 {
+			fmt.Fprintf(&b, "- Tags: %s\n", strings.Join(r.CreatedTags, ", "))
+		}
+	}
+	return b.String()
+}
+
+// markdownCell escapes text for a Markdown table cell.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// writeReport writes the report to REPORT_JSON and REPORT_MARKDOWN ("-" for
+// stdout) and posts the JSON to REPORT_WEBHOOK, whichever are set. Failures are
+// logged, not fatal.
+func writeReport(ctx context.Context, r *runReport) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		slog.ErrorContext(ctx, "encoding run report failed", "error", err)
+		return
+	}
+	if path := os.Getenv("REPORT_JSON"); path != "" {
+		if err := writeOutput(path, append(data, '\n')); err != nil {
+			slog.ErrorContext(ctx, "writing JSON run report failed", "path", path, "error", err)
+		}
+	}
+	if path := os.Getenv("REPORT_MARKDOWN"); path != "" {
+		if err := writeOutput(path, []byte(r.markdown())); err != nil {
+			slog.ErrorContext(ctx, "writing Markdown run report failed", "path", path, "error", err)
+		}
+	}
+	if url := os.Getenv("REPORT_WEBHOOK"); url != "" {
+		if err := postReport(ctx, url, data); err != nil {
+			slog.ErrorContext(ctx, "posting run report failed", "error", err)
+		}
+	}
+}
+
+func writeOutput(path string, data []byte) error {
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// postReport sends the JSON report to a webhook, expecting a 2xx response.
+func postReport(ctx context.Context, url string, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bartlettc22/paperless-llm-processor/internal/paperless"
+)
+
+// selection controls which documents a run processes.
+type selection struct {
+	filter paperless.DocumentFilter
+	// force processes selected documents even if they are already processed or skipped.
+	force bool
+	// limit caps the number of documents processed. 0 means no limit.
+	limit int
+}
+
+// orderings maps ORDER shorthands to Paperless-ngx ordering fields.
+var orderings = map[string]string{
+	"newest": "-created",
+	"oldest": "created",
+}
+
+// orderingFields are the document fields Paperless-ngx can order by.
+var orderingFields = []string{
+	"id", "title", "created", "modified", "added", "archive_serial_number",
+	"correspondent__name", "document_type__name", "owner", "num_notes", "page_count",
+}
+
+// selectionFromEnv reads the document selection settings:
+// DOCUMENT_IDS (comma-separated), DOCUMENT_QUERY (full-text query),
+// FILTER_TAGS, FILTER_CORRESPONDENTS and FILTER_DOCUMENT_TYPES (comma-separated names),
+// ADDED_AFTER, ADDED_BEFORE, CREATED_AFTER and CREATED_BEFORE (YYYY-MM-DD, inclusive),
+// SAVED_VIEW (saved view ID), FORCE (ignore llm-process-id and llm-skip),
+// LIMIT and ORDER (newest, oldest or a Paperless-ngx ordering field such as -added).
+// FORCE without a filter or LIMIT would reprocess the whole library, so it also
+// needs FORCE_ALL=true.
+func selectionFromEnv(tagIDByName, corrIDByName, docTypeIDByName map[string]int) (selection, error) {
+	var sel selection
+	var err error
+
+	if v := os.Getenv("DOCUMENT_IDS"); v != "" {
+		for _, s := range strings.Split(v, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return sel, fmt.Errorf("DOCUMENT_IDS: invalid ID %q", s)
+			}
+			sel.filter.IDs = append(sel.filter.IDs, id)
+		}
+	}
+	sel.filter.Query = os.Getenv("DOCUMENT_QUERY")
+
+	if sel.filter.Tags, err = idsByName("FILTER_TAGS", tagIDByName); err != nil {
+		return sel, err
+	}
+	if sel.filter.Correspondents, err = idsByName("FILTER_CORRESPONDENTS", corrIDByName); err != nil {
+		return sel, err
+	}
+	if sel.filter.DocumentTypes, err = idsByName("FILTER_DOCUMENT_TYPES", docTypeIDByName); err != nil {
+		return sel, err
+	}
+
+	for env, dst := range map[string]*string{
+		"ADDED_AFTER":    &sel.filter.AddedAfter,
+		"ADDED_BEFORE":   &sel.filter.AddedBefore,
+		"CREATED_AFTER":  &sel.filter.CreatedAfter,
+		"CREATED_BEFORE": &sel.filter.CreatedBefore,
+	} {
+		v := os.Getenv(env)
+		if v == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, v); err != nil {
+			return sel, fmt.Errorf("%s: expected YYYY-MM-DD, got %q", env, v)
+		}
+		*dst = v
+	}
+
+	if v := os.Getenv("SAVED_VIEW"); v != "" {
+		if sel.filter.SavedView, err = strconv.Atoi(v); err != nil {
+			return sel, fmt.Errorf("SAVED_VIEW: invalid ID %q", v)
+		}
+	}
+
+	if v := os.Getenv("FORCE"); v != "" {
+		if sel.force, err = strconv.ParseBool(v); err != nil {
+			return sel, fmt.Errorf("FORCE: %w", err)
+		}
+	}
+	if v := os.Getenv("LIMIT"); v != "" {
+		if sel.limit, err = strconv.Atoi(v); err != nil || sel.limit < 0 {
+			return sel, fmt.Errorf("LIMIT: invalid value %q", v)
+		}
+	}
+	if v := os.Getenv("ORDER"); v != "" {
+		ordering, ok := orderings[v]
+		if !ok {
+			ordering = v
+		}
+		if !slices.Contains(orderingFields, strings.TrimPrefix(ordering, "-")) {
+			return sel, fmt.Errorf("ORDER: %q is not one of newest, oldest or [-]%s", v, strings.Join(orderingFields, ", [-]"))
+		}
+		sel.filter.Ordering = ordering
+	}
+
+	if sel.force && sel.filter.Empty() && sel.limit == 0 {
+		all := false
+		if v := os.Getenv("FORCE_ALL"); v != "" {
+			if all, err = strconv.ParseBool(v); err != nil {
+				return sel, fmt.Errorf("FORCE_ALL: %w", err)
+			}
+		}
+		if !all {
+			return sel, errors.New("FORCE without a filter or LIMIT reprocesses every document; set FORCE_ALL=true to confirm")
+		}
+	}
+
+	return sel, nil
+}
+
+// idsByName resolves the comma-separated names in the env variable to IDs.
+func idsByName(env string, idByName map[string]int) ([]int, error) {
+	v := os.Getenv(env)
+	if v == "" {
+		return nil, nil
+	}
+	var ids []int
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		id, ok := idByName[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown name %q", env, name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// selectDocuments streams the documents to process: the unprocessed documents
+// matching the filter or, when forced, every matching document. It stops after
+// sel.limit documents.
+func selectDocuments(ctx context.Context, client *paperless.Client, sel selection, fieldName string, processID int, skipFieldName string) iter.Seq2[paperless.Document, error] {
+	docs := client.UnprocessedDocuments(ctx, sel.filter, fieldName, processID, skipFieldName)
+	if sel.force {
+		docs = client.DocumentsMatching(ctx, sel.filter)
+	}
+	if sel.limit == 0 {
+		return docs
+	}
+	return func(yield func(paperless.Document, error) bool) {
+		n := 0
+		for doc, err := range docs {
+			if !yield(doc, err) || err != nil {
+				return
+			}
+			if n++; n >= sel.limit {
+				return
+			}
+		}
+	}
+}
@@ -1,46 +1,243 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/bartlettc22/paperless-llm-processor/api"
+	"github.com/bartlettc22/paperless-llm-processor/internal/apierror"
+	"github.com/bartlettc22/paperless-llm-processor/internal/auth"
+	"github.com/bartlettc22/paperless-llm-processor/internal/converter"
 	"github.com/bartlettc22/paperless-llm-processor/internal/handler"
+	"github.com/bartlettc22/paperless-llm-processor/internal/jobs"
+	"github.com/bartlettc22/paperless-llm-processor/internal/logging"
+	"github.com/bartlettc22/paperless-llm-processor/internal/metrics"
 	"github.com/bartlettc22/paperless-llm-processor/internal/ollama"
 	"github.com/bartlettc22/paperless-llm-processor/internal/paperless"
+	"github.com/bartlettc22/paperless-llm-processor/internal/pipeline"
 )
 
 func main() {
 	ollamaURL := flag.String("ollama-url", "http://localhost:11434", "Ollama API base URL")
 	model := flag.String("model", "glm-ocr:latest", "Ollama model to use for analysis")
 	port := flag.Int("port", 8080, "HTTP server port")
+	rasterDPI := flag.Int("raster-dpi", 0, "PDF rasterization DPI (0 = model default)")
+	rasterColor := flag.String("raster-color", "", "Page color mode: gray or color (empty = model default)")
+	rasterFormat := flag.String("raster-format", "", "Page image format: jpeg or png (empty = model default)")
+	rasterQuality := flag.Int("raster-quality", 0, "JPEG quality 1-100 (0 = model default)")
+	rasterMaxDim := flag.Int("raster-max-dimension", 0, "Max page long edge in pixels (0 = model default)")
+	tileGrid := flag.String("tile-grid", "", "Split pages into COLSxROWS overlapping tiles, e.g. 2x3 (empty = no tiling)")
+	tileOverlap := flag.Float64("tile-overlap", 0.1, "Fraction of each tile shared with its neighbours")
+	tileMaxDim := flag.Int("tile-max-dimension", 0, "Page long edge in pixels before splitting into tiles (0 = max dimension × larger grid side)")
+	preprocess := flag.String("preprocess", "", "Comma-separated image preprocessing steps: crop, rotate, deskew, contrast, binarize, all")
+	jobWorkers := flag.Int("job-workers", 1, "Number of analyses run concurrently")
+	jobQueueSize := flag.Int("job-queue-size", 16, "Max analyses waiting for a worker before /analyze returns 503")
+	jobTTL := flag.Duration("job-ttl", time.Hour, "How long finished analysis results are kept")
+	authFile := flag.String("auth-file", "", "JSON credentials file enabling bearer token / basic auth (empty = no auth)")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file (serves HTTPS together with -tls-key)")
+	tlsKey := flag.String("tls-key", "", "TLS private key file")
+	storagePathMode := flag.String("storage-path-mode", "", "How /documents/{id}/process picks storage paths: rules or llm (empty = leave alone)")
+	storagePathRules := flag.String("storage-path-rules", "", "JSON storage path rules file for -storage-path-mode=rules")
+	permissionRules := flag.String("permission-rules", "", "JSON permission rules file for /documents/{id}/process (empty = leave ownership alone)")
+	summaryNote := flag.Bool("summary-note", false, "Also write the summary as a document note in /documents/{id}/process")
+	processModels := flag.String("process-models", "", "Comma-separated models /documents/{id}/process requests may choose instead of -model")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 5*time.Minute, "How long to wait for in-flight analyses on SIGINT/SIGTERM before canceling them")
+	logFormat := flag.String("log-format", "text", "Log format: text or json")
+	logLevel := flag.String("log-level", "info", "Log level: debug, info, warn or error")
 	flag.Parse()
 
+	if err := logging.Setup(os.Stderr, *logFormat, *logLevel); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	rasterOpts := converter.OptionsForModel(*model)
+	if *rasterDPI > 0 {
+		rasterOpts.DPI = *rasterDPI
+	}
+	switch strings.ToLower(*rasterColor) {
+	case "":
+	case "gray":
+		rasterOpts.Gray = true
+	case "color":
+		rasterOpts.Gray = false
+	default:
+		logging.Fatal("invalid -raster-color (must be gray or color)", "value", *rasterColor)
+	}
+	if *rasterFormat != "" {
+		rasterOpts.Format = strings.ToLower(*rasterFormat)
+	}
+	if *rasterQuality > 0 {
+		rasterOpts.Quality = *rasterQuality
+	}
+	if *rasterMaxDim > 0 {
+		rasterOpts.MaxDimension = *rasterMaxDim
+	}
+	if *tileGrid != "" {
+		cols, rows, err := converter.ParseGrid(*tileGrid)
+		if err != nil {
+			logging.Fatal("invalid -tile-grid", "error", err)
+		}
+		rasterOpts.Tiles = converter.TileOptions{Cols: cols, Rows: rows, Overlap: *tileOverlap, MaxDimension: *tileMaxDim}
+	}
+	if *preprocess != "" {
+		p, err := converter.ParsePreprocess(*preprocess)
+		if err != nil {
+			logging.Fatal("invalid -preprocess", "error", err)
+		}
+		rasterOpts.Preprocess = p
+	}
+	if err := rasterOpts.Validate(); err != nil {
+		logging.Fatal("invalid rasterization settings", "error", err)
+	}
+
+	var models []string
+	for _, m := range strings.Split(*processModels, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			models = append(models, m)
+		}
+	}
+
 	client := ollama.NewClient(*ollamaURL, *model)
 
 	var paperlessClient *paperless.Client
 	paperlessURL := os.Getenv("PAPERLESS_URL")
 	paperlessToken := os.Getenv("PAPERLESS_TOKEN")
 	if paperlessURL != "" && paperlessToken != "" {
-		paperlessClient = paperless.NewClient(paperlessURL, paperlessToken)
-		log.Printf("Paperless-ngx configured at %s", paperlessURL)
+		transportOpts, err := paperless.TransportOptionsFromEnv()
+		if err != nil {
+			logging.Fatal("invalid Paperless client settings", "error", err)
+		}
+		paperlessClient = paperless.NewClientWithOptions(paperlessURL, paperlessToken, transportOpts)
+		slog.Info("Paperless-ngx configured", "url", paperlessURL)
+		if server, err := paperlessClient.Detect(context.Background()); err != nil {
+			slog.Warn("failed to detect Paperless-ngx version, assuming all features", "error", err)
+		} else {
+			slog.Info("Paperless-ngx detected", "version", server.Version, "api_version", server.APIVersion, "using_api_version", paperlessClient.APIVersion)
+		}
+	} else {
+		slog.Info("Paperless-ngx not configured (set PAPERLESS_URL and PAPERLESS_TOKEN)")
+	}
+
+	var rules pipeline.Rules
+	if *storagePathMode != "" || *permissionRules != "" || *summaryNote {
+		if paperlessClient == nil {
+			logging.Fatal("-storage-path-mode, -permission-rules and -summary-note require PAPERLESS_URL and PAPERLESS_TOKEN")
+		}
+		if *storagePathMode == pipeline.StoragePathRules && *storagePathRules == "" {
+			logging.Fatal("-storage-path-rules is required with -storage-path-mode=rules")
+		}
+		var err error
+		rules, err = pipeline.LoadRules(context.Background(), paperlessClient, *storagePathMode, *storagePathRules, *permissionRules)
+		if err != nil {
+			logging.Fatal("failed to load storage path and permission rules", "error", err)
+		}
+		slog.Info("loaded rules", "storage_path_mode", rules.StoragePathMode,
+			"storage_path_rules", len(rules.StoragePaths), "permission_rules", len(rules.Permissions))
+		if *summaryNote && !paperlessClient.Supports(paperless.CapNotes) {
+			logging.Fatal("-summary-note requires document notes, which this Paperless-ngx does not support")
+		}
+	}
+
+	if *jobWorkers < 1 || *jobQueueSize < 1 {
+		logging.Fatal("-job-workers and -job-queue-size must be at least 1")
+	}
+	queue := jobs.NewQueue(*jobWorkers, *jobQueueSize, *jobTTL)
+	metrics.RegisterQueueDepth(*model, queue.Len)
+	jobsHandler := &handler.JobsHandler{Queue: queue}
+
+	var authn *auth.Authenticator
+	if *authFile != "" {
+		var err error
+		authn, err = auth.LoadFile(*authFile)
+		if err != nil {
+			logging.Fatal("invalid -auth-file", "error", err)
+		}
+		slog.Info("authentication enabled", "auth_file", *authFile)
 	} else {
-		log.Println("Paperless-ngx not configured (set PAPERLESS_URL and PAPERLESS_TOKEN)")
+		slog.Warn("authentication disabled (set -auth-file)")
+	}
+	if (*tlsCert == "") != (*tlsKey == "") {
+		logging.Fatal("-tls-cert and -tls-key must be set together")
+	}
+
+	// accepting rejects new work while the queue drains on shutdown
+	accepting := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if queue.Draining() {
+				apierror.Write(w, r, &apierror.Error{Status: http.StatusServiceUnavailable, Code: api.CodeShuttingDown, Message: jobs.ErrDraining.Error(), Retryable: true})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
 	}
 
 	mux := http.NewServeMux()
-	mux.Handle("/analyze", &handler.AnalyzeHandler{Client: client, DebugDir: "debug-images"})
-	mux.Handle("/documents", &handler.DocumentsHandler{Client: paperlessClient})
+	mux.Handle("/analyze", authn.Require(auth.ScopeAnalyze, accepting(&handler.AnalyzeHandler{Client: client, DebugDir: "debug-images", Raster: rasterOpts, Jobs: queue, Paperless: paperlessClient})))
+	mux.Handle("GET /jobs/{id}", authn.Require(auth.ScopeAnalyze, http.HandlerFunc(jobsHandler.Status)))
+	mux.Handle("GET /jobs/{id}/result", authn.Require(auth.ScopeAnalyze, http.HandlerFunc(jobsHandler.Result)))
+	mux.Handle("POST /jobs/{id}/cancel", authn.Require(auth.ScopeAnalyze, http.HandlerFunc(jobsHandler.Cancel)))
+	mux.Handle("/documents", authn.Require(auth.ScopeReadDocuments, &handler.DocumentsHandler{Client: paperlessClient}))
+	mux.Handle("POST /documents/{id}/process", authn.Require(auth.ScopeProcess, accepting(&handler.ProcessHandler{
+		Paperless:   paperlessClient,
+		Ollama:      client,
+		DebugDir:    "debug-images",
+		Raster:      rasterOpts,
+		Rules:       rules,
+		SummaryNote: *summaryNote,
+		Models:      models,
+	})))
+	mux.Handle("GET /metrics", authn.Require(auth.ScopeMetrics, metrics.Handler()))
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintln(w, "ok")
 	})
+	mux.Handle("GET /ready", &handler.ReadyHandler{Ollama: client, Paperless: paperlessClient, Jobs: queue})
+	mux.HandleFunc("GET /openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(api.Spec)
+	})
+
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", *port), Handler: handler.RequestID(mux)}
+	serveErr := make(chan error, 1)
+	go func() {
+		if *tlsCert != "" {
+			slog.Info("starting HTTPS server", "addr", srv.Addr, "ollama", *ollamaURL, logging.KeyModel, *model)
+			serveErr <- srv.ListenAndServeTLS(*tlsCert, *tlsKey)
+		} else {
+			slog.Info("starting server", "addr", srv.Addr, "ollama", *ollamaURL, logging.KeyModel, *model)
+			serveErr <- srv.ListenAndServe()
+		}
+	}()
 
-	addr := fmt.Sprintf(":%d", *port)
-	log.Printf("Starting server on %s (ollama=%s, model=%s)", addr, *ollamaURL, *model)
-	if err := http.ListenAndServe(addr, mux); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	select {
+	case err := <-serveErr:
+		logging.Fatal("server failed", "error", err)
+	case <-sigCtx.Done():
+	}
+	stop()
+
+	// Keep serving job status and results while queued and running analyses
+	// finish, then stop accepting connections and wait for in-flight requests.
+	slog.Info("shutting down, draining in-flight analyses", "timeout", *shutdownTimeout, "queued", queue.Len())
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := queue.Drain(ctx); err != nil {
+		slog.Warn("shutdown timeout reached, canceled remaining analyses", "error", err)
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("closing server", "error", err)
+		srv.Close()
 	}
+	slog.Info("server stopped")
 }
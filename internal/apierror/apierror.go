@@ -0,0 +1,200 @@
+// Package apierror writes the server's JSON error responses and maps failures of
+// the services it depends on (Ollama, Paperless-ngx, pdftoppm) to HTTP statuses.
+package apierror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os/exec"
+
+	"github.com/bartlettc22/paperless-llm-processor/api"
+	"github.com/bartlettc22/paperless-llm-processor/internal/converter"
+	"github.com/bartlettc22/paperless-llm-processor/internal/logging"
+	"github.com/bartlettc22/paperless-llm-processor/internal/ollama"
+	"github.com/bartlettc22/paperless-llm-processor/internal/paperless"
+)
+
+// Upstream service names used in error messages.
+const (
+	Ollama    = "ollama"
+	Paperless = "paperless-ngx"
+	Pdftoppm  = "pdftoppm"
+)
+
+// Error is a failure reported to the client. Error() returns only Message, so
+// upstream response bodies in Err reach the logs but not the client.
+type Error struct {
+	Status    int
+	Code      string
+	Message   string
+	Retryable bool
+	// Err is the underlying cause, if any.
+	Err error
+	// RequestID overrides the ID of the request reporting the error. Background
+	// jobs set it to the ID of the request that submitted them.
+	RequestID string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// API returns the error as sent to the client.
+func (e *Error) API(requestID string) *api.Error {
+	if e.RequestID != "" {
+		requestID = e.RequestID
+	}
+	return &api.Error{Code: e.Code, Message: e.Message, Retryable: e.Retryable, RequestID: requestID}
+}
+
+// New returns a non-retryable error.
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Invalid returns a 400 invalid_request error.
+func Invalid(message string) *Error {
+	return New(http.StatusBadRequest, api.CodeInvalidRequest, message)
+}
+
+// MethodNotAllowed is returned for a method an endpoint does not support.
+var MethodNotAllowed = New(http.StatusMethodNotAllowed, api.CodeMethodNotAllowed, "method not allowed")
+
+// NotConfigured is returned by endpoints that need Paperless-ngx when it is not configured.
+var NotConfigured = New(http.StatusServiceUnavailable, api.CodeNotConfigured, "paperless-ngx not configured (set PAPERLESS_URL and PAPERLESS_TOKEN)")
+
+// Upstream classifies a failure of an upstream service. The message is msg
+// followed by a summary of the failure:
+//
+//   - timeouts are 504 upstream_timeout
+//   - unreachable services, and upstream 503s, are 503 upstream_unavailable
+//   - a model Ollama does not have is 502 model_not_found
+//   - other upstream statuses, and unusable responses, are 502 upstream_error
+//
+// Timeouts, unreachable services and upstream 5xx and 429 statuses are retryable.
+func Upstream(service, msg string, err error) *Error {
+	e := &Error{Status: http.StatusBadGateway, Code: api.CodeUpstreamError, Err: err}
+	reason := fmt.Sprintf("%s request failed", service)
+
+	var (
+		netErr       net.Error
+		ollamaErr    *ollama.StatusError
+		paperlessErr *paperless.StatusError
+		pdftoppmErr  *converter.PdftoppmError
+		exitErr      *exec.ExitError
+	)
+	switch {
+	case errors.Is(err, context.Canceled):
+		e.Status, e.Code, e.Retryable = http.StatusServiceUnavailable, api.CodeCanceled, true
+		reason = "canceled"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		e.Status, e.Code, e.Retryable = http.StatusGatewayTimeout, api.CodeUpstreamTimeout, true
+		reason = fmt.Sprintf("%s timed out", service)
+	case errors.Is(err, exec.ErrNotFound):
+		e.Status, e.Code = http.StatusServiceUnavailable, api.CodeUpstreamUnavailable
+		reason = fmt.Sprintf("%s is not installed", service)
+	case errors.Is(err, ollama.ErrModelNotFound),
+		errors.As(err, &ollamaErr) && ollamaErr.StatusCode == http.StatusNotFound:
+		e.Code = api.CodeModelNotFound
+		reason = "model not found"
+	case errors.As(err, &ollamaErr):
+		reason = e.upstreamStatus(service, ollamaErr.StatusCode)
+	case errors.As(err, &paperlessErr):
+		reason = e.upstreamStatus(service, paperlessErr.StatusCode)
+	case errors.As(err, &pdftoppmErr) && errors.As(err, &exitErr):
+		// Exit status 1 means pdftoppm could not open the PDF.
+		if exitErr.ExitCode() == 1 {
+			e.Status, e.Code = http.StatusUnprocessableEntity, api.CodeInvalidDocument
+			reason = "not a readable PDF"
+		} else {
+			reason = fmt.Sprintf("%s exited with status %d", service, exitErr.ExitCode())
+		}
+	case errors.As(err, &netErr):
+		e.Status, e.Code, e.Retryable = http.StatusServiceUnavailable, api.CodeUpstreamUnavailable, true
+		reason = fmt.Sprintf("%s is unreachable", service)
+	}
+
+	e.Message = reason
+	if msg != "" {
+		e.Message = msg + ": " + reason
+	}
+	return e
+}
+
+// upstreamStatus sets the status for an upstream HTTP status and returns the reason.
+func (e *Error) upstreamStatus(service string, status int) string {
+	switch {
+	case status == http.StatusServiceUnavailable:
+		e.Status, e.Code = http.StatusServiceUnavailable, api.CodeUpstreamUnavailable
+	case status == http.StatusGatewayTimeout:
+		e.Status, e.Code = http.StatusGatewayTimeout, api.CodeUpstreamTimeout
+	}
+	e.Retryable = status >= 500 || status == http.StatusTooManyRequests
+	return fmt.Sprintf("%s returned status %d", service, status)
+}
+
+// Conversion classifies a failure to rasterize a document: pdftoppm failures as
+// in Upstream, anything else as a 422 invalid_document. The message is msg
+// followed by a fixed reason; the cause stays in Err.
+func Conversion(msg string, err error) *Error {
+	var pdftoppmErr *converter.PdftoppmError
+	if errors.As(err, &pdftoppmErr) {
+		return Upstream(Pdftoppm, msg, err)
+	}
+	reason := "not a readable document"
+	if errors.Is(err, converter.ErrUnsupportedType) {
+		reason = "unsupported file type"
+	}
+	return &Error{Status: http.StatusUnprocessableEntity, Code: api.CodeInvalidDocument, Message: msg + ": " + reason, Err: err}
+}
+
+// From returns err as an *Error. Cancellation becomes 503 canceled and any other
+// error 500 internal.
+func From(err error) *Error {
+	var e *Error
+	switch {
+	case errors.As(err, &e):
+		return e
+	case errors.Is(err, context.Canceled):
+		return &Error{Status: http.StatusServiceUnavailable, Code: api.CodeCanceled, Message: "canceled", Retryable: true, Err: err}
+	default:
+		return &Error{Status: http.StatusInternalServerError, Code: api.CodeInternal, Message: "internal error", Err: err}
+	}
+}
+
+// Log logs err with its cause, at error level for 5xx statuses.
+func Log(ctx context.Context, msg string, err error) {
+	e := From(err)
+	level := slog.LevelInfo
+	if e.Status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	args := []any{"status", e.Status, "code", e.Code, "message", e.Message}
+	if e.Err != nil {
+		args = append(args, "error", e.Err)
+	}
+	slog.Log(ctx, level, msg, args...)
+}
+
+// Write sends err as a JSON error response carrying the request's ID, and logs
+// it with its cause.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	Log(r.Context(), "request failed", err)
+	e := From(err)
+	if e.Retryable && e.Status == http.StatusServiceUnavailable && w.Header().Get("Retry-After") == "" {
+		w.Header().Set("Retry-After", "30")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(api.ErrorResponse{Error: *e.API(logging.RequestID(r.Context()))})
+}
@@ -0,0 +1,152 @@
+// Package auth authenticates server requests with static bearer tokens or HTTP
+// basic auth credentials loaded from a file, each granted a set of scopes.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/bartlettc22/paperless-llm-processor/api"
+	"github.com/bartlettc22/paperless-llm-processor/internal/apierror"
+)
+
+// Scope is a permission granted to a credential.
+type Scope string
+
+const (
+	// ScopeAnalyze allows uploading files to /analyze and managing analysis jobs.
+	ScopeAnalyze Scope = "analyze"
+	// ScopeReadDocuments allows listing Paperless-ngx documents.
+	ScopeReadDocuments Scope = "documents:read"
+	// ScopeProcess allows processing and updating Paperless-ngx documents.
+	ScopeProcess Scope = "process"
+	// ScopeMetrics allows scraping /metrics.
+	ScopeMetrics Scope = "metrics"
+)
+
+var validScopes = []Scope{ScopeAnalyze, ScopeReadDocuments, ScopeProcess, ScopeMetrics}
+
+// Credential is one entry of the credentials file. It authenticates with Token as
+// a bearer token, or with Username and Password through basic auth, or both.
+type Credential struct {
+	Name     string  `json:"name"`
+	Token    string  `json:"token,omitempty"`
+	Username string  `json:"username,omitempty"`
+	Password string  `json:"password,omitempty"`
+	Scopes   []Scope `json:"scopes"`
+}
+
+// Authenticator checks requests against a set of credentials.
+type Authenticator struct {
+	credentials []Credential
+	basic       bool
+}
+
+// LoadFile reads a JSON array of credentials.
+func LoadFile(path string) (*Authenticator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading credentials: %w", err)
+	}
+	var creds []Credential
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("parsing credentials %s: %w", path, err)
+	}
+	return New(creds)
+}
+
+// New validates the credentials and returns an Authenticator for them.
+func New(creds []Credential) (*Authenticator, error) {
+	a := &Authenticator{credentials: creds}
+	for i, c := range creds {
+		if c.Name == "" {
+			return nil, fmt.Errorf("credential %d: name must be set", i+1)
+		}
+		if c.Token == "" && c.Username == "" {
+			return nil, fmt.Errorf("credential %q: token or username must be set", c.Name)
+		}
+		if c.Username != "" && c.Password == "" {
+			return nil, fmt.Errorf("credential %q: password must be set with username", c.Name)
+		}
+		for _, s := range c.Scopes {
+			if !slices.Contains(validScopes, s) {
+				return nil, fmt.Errorf("credential %q: unknown scope %q", c.Name, s)
+			}
+		}
+		if c.Username != "" {
+			a.basic = true
+		}
+	}
+	return a, nil
+}
+
+type nameKey struct{}
+
+// Name returns the name of the credential that authenticated the request, if any.
+func Name(ctx context.Context) string {
+	name, _ := ctx.Value(nameKey{}).(string)
+	return name
+}
+
+// Require wraps next so that it is only served to requests whose credential has
+// the scope. A nil Authenticator allows every request.
+func (a *Authenticator) Require(scope Scope, next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cred := a.authenticate(r)
+		if cred == nil {
+			w.Header().Add("WWW-Authenticate", `Bearer realm="paperless-llm-processor"`)
+			if a.basic {
+				w.Header().Add("WWW-Authenticate", `Basic realm="paperless-llm-processor", charset="UTF-8"`)
+			}
+			apierror.Write(w, r, apierror.New(http.StatusUnauthorized, api.CodeUnauthorized, "unauthorized"))
+			return
+		}
+		if !slices.Contains(cred.Scopes, scope) {
+			apierror.Write(w, r, apierror.New(http.StatusForbidden, api.CodeForbidden, fmt.Sprintf("forbidden: credential %q lacks scope %q", cred.Name, scope)))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), nameKey{}, cred.Name)))
+	})
+}
+
+// authenticate returns the credential matching the request's Authorization header.
+func (a *Authenticator) authenticate(r *http.Request) *Credential {
+	header := r.Header.Get("Authorization")
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+		for i, c := range a.credentials {
+			if c.Token != "" && equal(c.Token, strings.TrimSpace(token)) {
+				return &a.credentials[i]
+			}
+		}
+		return nil
+	}
+	if username, password, ok := r.BasicAuth(); ok && a.basic {
+		for i, c := range a.credentials {
+			// Check both so the comparison time does not depend on which one differs
+			userOK := equal(c.Username, username)
+			passOK := equal(c.Password, password)
+			if c.Username != "" && userOK && passOK {
+				return &a.credentials[i]
+			}
+		}
+	}
+	return nil
+}
+
+// equal compares secrets in constant time, hashing first so that the length of
+// the expected value is not revealed either.
+func equal(expected, given string) bool {
+	e := sha256.Sum256([]byte(expected))
+	g := sha256.Sum256([]byte(given))
+	return subtle.ConstantTimeCompare(e[:], g[:]) == 1
+}
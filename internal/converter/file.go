@@ -0,0 +1,268 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
+)
+
+// File types recognized by DetectType.
+const (
+	TypePDF  = "pdf"
+	TypeJPEG = "jpeg"
+	TypePNG  = "png"
+	TypeGIF  = "gif"
+	TypeWebP = "webp"
+	TypeTIFF = "tiff"
+	TypeBMP  = "bmp"
+)
+
+// ErrUnsupportedType is returned when a file is not a PDF or a supported image format.
+var ErrUnsupportedType = errors.New("unsupported file type")
+
+// maxTIFFFrames guards against malformed TIFFs whose IFD chain never ends.
+const maxTIFFFrames = 10000
+
+// DetectType sniffs the file type from its content. It returns ErrUnsupportedType
+// (wrapped with the detected MIME type) for anything the converter cannot handle.
+func DetectType(data []byte) (string, error) {
+	if isTIFF(data) {
+		return TypeTIFF, nil
+	}
+
+	contentType := http.DetectContentType(data)
+	switch {
+	case strings.HasPrefix(contentType, "application/pdf"):
+		return TypePDF, nil
+	case strings.HasPrefix(contentType, "image/jpeg"):
+		return TypeJPEG, nil
+	case strings.HasPrefix(contentType, "image/png"):
+		return TypePNG, nil
+	case strings.HasPrefix(contentType, "image/gif"):
+		return TypeGIF, nil
+	case strings.HasPrefix(contentType, "image/webp"):
+		return TypeWebP, nil
+	case strings.HasPrefix(contentType, "image/bmp"):
+		return TypeBMP, nil
+	default:
+		return "", fmt.Errorf("%w: %s (supported: PDF, JPEG, PNG, GIF, WebP, TIFF, BMP)", ErrUnsupportedType, contentType)
+	}
+}
+
+// FileToPages detects the type of data and converts it to one Page per document page.
+// PDFs are rasterized with pdftoppm, multi-page TIFFs yield one page per frame, and
+// other images yield a single page. Images are decoded and normalized to the same
+// size and encoding as PDF pages. Pages are also saved to debugDir for inspection.
+func FileToPages(data []byte, debugDir string, opts Options) ([]Page, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	fileType, err := DetectType(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch fileType {
+	case TypePDF:
+		tmpFile, err := os.CreateTemp("", "doc-*.pdf")
+		if err != nil {
+			return nil, fmt.Errorf("creating temp file: %w", err)
+		}
+		defer os.Remove(tmpFile.Name())
+		if _, err := tmpFile.Write(data); err != nil {
+			tmpFile.Close()
+			return nil, fmt.Errorf("writing temp file: %w", err)
+		}
+		tmpFile.Close()
+		return PDFToPages(tmpFile.Name(), debugDir, opts)
+
+	default:
+		return imageToPages(fileType, data, debugDir, opts)
+	}
+}
+
+// imageToPages decodes an image file into its frames and normalizes each one
+// (orientation, size, color) before re-encoding it in opts.Format, so every page
+// the model sees has consistent dimensions regardless of source format. When
+// opts.Tiles is enabled each frame is also normalized at the tile resolution and
+// split into tiles, as PDFToPages does for PDF pages.
+func imageToPages(fileType string, data []byte, debugDir string, opts Options) ([]Page, error) {
+	frames, err := decodeFrames(fileType, data)
+	if err != nil {
+		return nil, err
+	}
+
+	orientation := 1
+	if fileType == TypeJPEG {
+		orientation = jpegOrientation(data)
+	}
+
+	hiRes := opts
+	hiRes.MaxDimension = opts.Tiles.maxDimension(opts.MaxDimension)
+
+	pages := make([]Page, 0, len(frames))
+	for i, frame := range frames {
+		name := fmt.Sprintf("image-%d%s", i+1, opts.Ext())
+		encoded, err := encodeImage(normalizeImage(frame, orientation, opts), opts)
+		if err != nil {
+			return nil, fmt.Errorf("encoding page %d: %w", i+1, err)
+		}
+		img, geo, err := encodedImageToBase64(name, encoded, debugDir, opts)
+		if err != nil {
+			return nil, err
+		}
+		page := Page{Image: img}
+
+		if opts.Tiles.Enabled() {
+			encoded, err := encodeImage(normalizeImage(frame, orientation, hiRes), opts)
+			if err != nil {
+				return nil, fmt.Errorf("encoding page %d for tiling: %w", i+1, err)
+			}
+			if err := tilePage(&page, rasterPage{Name: name, Data: encoded}, geo, debugDir, opts); err != nil {
+				return nil, fmt.Errorf("page %d: %w", i+1, err)
+			}
+		}
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
+// encodedImageToBase64 saves data to debugDir as name, preprocesses it if enabled
+// and returns the base64-encoded result with the geometry it corrected.
+func encodedImageToBase64(name string, data []byte, debugDir string, opts Options) (string, geometry, error) {
+	p := rasterPage{Name: name, Data: data}
+	if err := writeDebugImage(debugDir, p.Name, p.Data); err != nil {
+		return "", geometry{}, err
+	}
+	data, geo, err := preprocessPage(p, debugDir, opts)
+	if err != nil {
+		return "", geometry{}, err
+	}
+	return base64.StdEncoding.EncodeToString(data), geo, nil
+}
+
+// decodeFrames decodes an image file into one image per page.
+func decodeFrames(fileType string, data []byte) ([]image.Image, error) {
+	switch fileType {
+	case TypeJPEG, TypePNG, TypeGIF:
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", strings.ToUpper(fileType), err)
+		}
+		return []image.Image{img}, nil
+	case TypeTIFF:
+		return decodeTIFFFrames(data)
+	case TypeBMP:
+		img, err := bmp.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("decoding BMP: %w", err)
+		}
+		return []image.Image{img}, nil
+	case TypeWebP:
+		img, err := webp.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("decoding WebP: %w", err)
+		}
+		return []image.Image{img}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, fileType)
+	}
+}
+
+func isTIFF(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*"))
+}
+
+// decodeTIFFFrames decodes every image in a multi-page TIFF. The tiff package only
+// reads the first IFD, so each frame is decoded through a view of the file whose
+// header points at that frame's IFD instead.
+func decodeTIFFFrames(data []byte) ([]image.Image, error) {
+	order, offsets, err := tiffIFDOffsets(data)
+	if err != nil {
+		return nil, err
+	}
+
+	frames := make([]image.Image, 0, len(offsets))
+	for i, off := range offsets {
+		view := &tiffFrameView{data: data}
+		copy(view.header[:], data[:8])
+		order.PutUint32(view.header[4:], off)
+
+		img, err := tiff.Decode(io.NewSectionReader(view, 0, int64(len(data))))
+		if err != nil {
+			return nil, fmt.Errorf("decoding TIFF page %d: %w", i+1, err)
+		}
+		frames = append(frames, img)
+	}
+	return frames, nil
+}
+
+// tiffIFDOffsets walks the IFD chain of a classic TIFF and returns its byte order
+// and the offset of every IFD.
+func tiffIFDOffsets(data []byte) (binary.ByteOrder, []uint32, error) {
+	if len(data) < 8 {
+		return nil, nil, errors.New("TIFF too short")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if data[0] == 'M' {
+		order = binary.BigEndian
+	}
+
+	var offsets []uint32
+	seen := make(map[uint32]bool)
+	off := order.Uint32(data[4:8])
+	for off != 0 {
+		if seen[off] || len(offsets) >= maxTIFFFrames {
+			return nil, nil, errors.New("TIFF has a looping IFD chain")
+		}
+		if int(off)+2 > len(data) {
+			return nil, nil, fmt.Errorf("TIFF IFD offset %d out of range", off)
+		}
+		seen[off] = true
+		offsets = append(offsets, off)
+
+		entries := int(order.Uint16(data[off : off+2]))
+		next := int(off) + 2 + entries*12
+		if next+4 > len(data) {
+			return nil, nil, fmt.Errorf("TIFF IFD at %d truncated", off)
+		}
+		off = order.Uint32(data[next : next+4])
+	}
+
+	if len(offsets) == 0 {
+		return nil, nil, errors.New("TIFF contains no images")
+	}
+	return order, offsets, nil
+}
+
+// tiffFrameView is an io.ReaderAt over a TIFF file with its 8-byte header replaced.
+type tiffFrameView struct {
+	data   []byte
+	header [8]byte
+}
+
+func (v *tiffFrameView) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(v.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, v.data[off:])
+	if off < int64(len(v.header)) {
+		copy(p, v.header[off:])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
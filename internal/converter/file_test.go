@@ -0,0 +1,45 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+func TestFileToPagesTilesImages(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 3000, 2000)), nil); err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultOptions()
+	opts.MaxDimension = 768
+	opts.Tiles = TileOptions{Cols: 2, Rows: 2, Overlap: 0.1}
+
+	pages, err := FileToPages(buf.Bytes(), "", opts)
+	if err != nil {
+		t.Fatalf("FileToPages: %v", err)
+	}
+	if len(pages) != 1 {
+		t.Fatalf("got %d pages, want 1", len(pages))
+	}
+	p := pages[0]
+	if len(p.Tiles) != 4 || p.Cols != 2 || p.Rows != 2 {
+		t.Fatalf("got %d tiles in a %dx%d grid, want 4 in 2x2", len(p.Tiles), p.Cols, p.Rows)
+	}
+
+	// Tiles are cut from a render at twice the page size, so each is
+	// larger than half the page.
+	data, err := base64.StdEncoding.DecodeString(p.Tiles[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width <= 768/2 {
+		t.Errorf("tile width %d, want more than %d", cfg.Width, 768/2)
+	}
+}
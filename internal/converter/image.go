@@ -0,0 +1,140 @@
+package converter
+
+import (
+	"encoding/binary"
+	"image"
+	"image/draw"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// normalizeImage prepares a decoded image for the model the same way pdftoppm
+// prepares PDF pages: it scales the long edge down to opts.MaxDimension, applies
+// the EXIF orientation and converts to grayscale when opts.Gray is set.
+// Scaling first keeps the pixel-by-pixel orientation transform cheap.
+func normalizeImage(img image.Image, orientation int, opts Options) image.Image {
+	img = fitToMaxDimension(img, opts.MaxDimension)
+	img = applyOrientation(img, orientation)
+	if opts.Gray {
+		img = toGray(img)
+	}
+	return img
+}
+
+// fitToMaxDimension scales img down so its long edge is at most maxDim pixels.
+// Images already within the limit, or a maxDim of 0, are returned unchanged.
+func fitToMaxDimension(img image.Image, maxDim int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if maxDim <= 0 || (w <= maxDim && h <= maxDim) {
+		return img
+	}
+
+	nw, nh := maxDim, h*maxDim/w
+	if h > w {
+		nw, nh = w*maxDim/h, maxDim
+	}
+	dst := newLike(img, max(nw, 1), max(nh, 1))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// applyOrientation transforms img so that it displays upright for the given
+// EXIF orientation value (1-8). Other values leave the image unchanged.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// Each case maps a source pixel (x, y) to its destination position.
+	var dw, dh int
+	var mapXY func(x, y int) (int, int)
+	switch orientation {
+	case 2: // mirrored horizontally
+		dw, dh = w, h
+		mapXY = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3: // rotated 180
+		return rotate180(img)
+	case 4: // mirrored vertically
+		dw, dh = w, h
+		mapXY = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5: // mirrored horizontally, rotated 270 clockwise
+		dw, dh = h, w
+		mapXY = func(x, y int) (int, int) { return y, x }
+	case 6: // rotated 90 clockwise
+		return rotate90(img)
+	case 7: // mirrored horizontally, rotated 90 clockwise
+		dw, dh = h, w
+		mapXY = func(x, y int) (int, int) { return h - 1 - y, w - 1 - x }
+	case 8: // rotated 270 clockwise
+		dw, dh = h, w
+		mapXY = func(x, y int) (int, int) { return y, w - 1 - x }
+	default:
+		return img
+	}
+
+	dst := newLike(img, dw, dh)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			nx, ny := mapXY(x, y)
+			dst.Set(nx, ny, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation tag of a JPEG file, or 1 if it has none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		// Start of scan: no more metadata segments follow
+		if marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : end]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+		pos = end
+	}
+	return 1
+}
+
+// exifOrientation reads the orientation tag (0x0112) from IFD0 of an EXIF TIFF block.
+func exifOrientation(tiffData []byte) int {
+	if len(tiffData) < 8 || !isTIFF(tiffData) {
+		return 1
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if tiffData[0] == 'M' {
+		order = binary.BigEndian
+	}
+
+	off := int(order.Uint32(tiffData[4:8]))
+	if off+2 > len(tiffData) {
+		return 1
+	}
+	entries := int(order.Uint16(tiffData[off : off+2]))
+	for i := 0; i < entries; i++ {
+		entry := off + 2 + i*12
+		if entry+12 > len(tiffData) {
+			return 1
+		}
+		if order.Uint16(tiffData[entry:entry+2]) == 0x0112 {
+			return int(order.Uint16(tiffData[entry+8 : entry+10]))
+		}
+	}
+	return 1
+}
@@ -0,0 +1,114 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Image output formats supported by the converter.
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+// Options controls how document pages are rasterized before being sent to the model.
+type Options struct {
+	// DPI is the rasterization resolution. pdftoppm ignores it when MaxDimension is set.
+	DPI int
+	// Gray renders pages in grayscale instead of color.
+	Gray bool
+	// Format is the output image format: "jpeg" or "png".
+	Format string
+	// Quality is the JPEG quality (1-100). Ignored for PNG.
+	Quality int
+	// MaxDimension caps the long edge of each page in pixels. 0 means no cap.
+	MaxDimension int
+	// Tiles optionally splits each page into overlapping high-resolution crops.
+	Tiles TileOptions
+	// Preprocess optionally cleans up page images before they are encoded.
+	Preprocess PreprocessOptions
+}
+
+// DefaultOptions returns the rasterization settings used when no model profile matches.
+func DefaultOptions() Options {
+	return Options{
+		DPI:          150,
+		Gray:         true,
+		Format:       FormatJPEG,
+		Quality:      80,
+		MaxDimension: 1568,
+	}
+}
+
+// OptionsForModel returns sensible rasterization defaults for the given Ollama model.
+// Small OCR-oriented models get a smaller page size to stay within their context,
+// while larger general vision models get color and a higher resolution.
+func OptionsForModel(model string) Options {
+	opts := DefaultOptions()
+	name := strings.ToLower(model)
+
+	switch {
+	case strings.HasPrefix(name, "glm-ocr"):
+		opts.MaxDimension = 768
+	case strings.HasPrefix(name, "qwen3-vl:8b"), strings.HasPrefix(name, "qwen3-vl:30b"):
+		opts.Gray = false
+		opts.Quality = 85
+		opts.MaxDimension = 1568
+	case strings.HasPrefix(name, "qwen3-vl"):
+		opts.MaxDimension = 1280
+	}
+
+	return opts
+}
+
+// Validate reports whether the options are usable.
+func (o Options) Validate() error {
+	if o.DPI < 0 {
+		return fmt.Errorf("invalid DPI %d", o.DPI)
+	}
+	if o.MaxDimension < 0 {
+		return fmt.Errorf("invalid max dimension %d", o.MaxDimension)
+	}
+	if err := o.Tiles.Validate(); err != nil {
+		return err
+	}
+	switch o.Format {
+	case FormatJPEG:
+		if o.Quality < 1 || o.Quality > 100 {
+			return fmt.Errorf("invalid JPEG quality %d (must be 1-100)", o.Quality)
+		}
+	case FormatPNG:
+	default:
+		return fmt.Errorf("unsupported image format %q (must be %q or %q)", o.Format, FormatJPEG, FormatPNG)
+	}
+	return nil
+}
+
+// Ext returns the file extension pdftoppm uses for the configured format.
+func (o Options) Ext() string {
+	if o.Format == FormatPNG {
+		return ".png"
+	}
+	return ".jpg"
+}
+
+// pdftoppmArgs builds the pdftoppm flags for these options.
+func (o Options) pdftoppmArgs() []string {
+	var args []string
+	switch o.Format {
+	case FormatPNG:
+		args = append(args, "-png")
+	default:
+		args = append(args, "-jpeg", "-jpegopt", fmt.Sprintf("quality=%d", o.Quality))
+	}
+	if o.Gray {
+		args = append(args, "-gray")
+	}
+	if o.DPI > 0 {
+		args = append(args, "-r", fmt.Sprint(o.DPI))
+	}
+	if o.MaxDimension > 0 {
+		args = append(args, "-scale-to", fmt.Sprint(o.MaxDimension))
+	}
+	return args
+}
@@ -9,11 +9,136 @@ import (
 	"sort"
 )
 
-// PDFToBase64Images converts a PDF file to a slice of base64-encoded JPEG images,
-// one per page. Uses grayscale, JPEG quality 80, and scales to max 1568px.
+// rasterPage is a single page image produced by pdftoppm.
+type rasterPage struct {
+	Name string
+	Data []byte
+}
+
+// PDFToBase64Images converts a PDF file to a slice of base64-encoded images,
+// one per page, rasterized according to opts.
 // Requires pdftoppm (poppler-utils) to be installed.
 // Images are also saved to debugDir for inspection.
-func PDFToBase64Images(pdfPath, debugDir string) ([]string, error) {
+func PDFToBase64Images(pdfPath, debugDir string, opts Options) ([]string, error) {
+	images, _, err := pdfImages(pdfPath, debugDir, opts)
+	return images, err
+}
+
+// pdfImages is PDFToBase64Images that also returns the geometry corrected on
+// each page, so the tiling render can be corrected the same way.
+func pdfImages(pdfPath, debugDir string, opts Options) ([]string, []geometry, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	pages, err := rasterizePDF(pdfPath, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	images := make([]string, 0, len(pages))
+	geos := make([]geometry, 0, len(pages))
+	for _, p := range pages {
+		if err := writeDebugImage(debugDir, p.Name, p.Data); err != nil {
+			return nil, nil, err
+		}
+		data, geo, err := preprocessPage(p, debugDir, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		images = append(images, base64.StdEncoding.EncodeToString(data))
+		geos = append(geos, geo)
+	}
+
+	return images, geos, nil
+}
+
+// PDFToPages converts a PDF file to one Page per PDF page. Each page always has a
+// downscaled whole-page image; when opts.Tiles is enabled the page is rendered a
+// second time at opts.Tiles resolution and split into overlapping tiles. The
+// tiling render is cropped, rotated and deskewed by the geometry found on the
+// whole-page image, so tiles and image show the same area the same way up.
+// Images and tiles are also saved to debugDir for inspection.
+func PDFToPages(pdfPath, debugDir string, opts Options) ([]Page, error) {
+	images, geos, err := pdfImages(pdfPath, debugDir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make([]Page, len(images))
+	for i, img := range images {
+		pages[i] = Page{Image: img}
+	}
+
+	if !opts.Tiles.Enabled() {
+		return pages, nil
+	}
+
+	hiRes := opts
+	hiRes.MaxDimension = opts.Tiles.maxDimension(opts.MaxDimension)
+	full, err := rasterizePDF(pdfPath, hiRes)
+	if err != nil {
+		return nil, fmt.Errorf("rasterizing pages for tiling: %w", err)
+	}
+	if len(full) != len(pages) {
+		return nil, fmt.Errorf("tiling render produced %d pages, expected %d", len(full), len(pages))
+	}
+
+	for i, p := range full {
+		if err := tilePage(&pages[i], p, geos[i], debugDir, opts); err != nil {
+			return nil, fmt.Errorf("page %d: %w", i+1, err)
+		}
+	}
+
+	return pages, nil
+}
+
+// tilePage splits p, a high-resolution render of page, into opts.Tiles tiles
+// after correcting it by geo, the geometry found on the whole-page image. Tiles
+// are also saved to debugDir for inspection.
+func tilePage(page *Page, p rasterPage, geo geometry, debugDir string, opts Options) error {
+	data := p.Data
+	if opts.Preprocess.Enabled() {
+		var err error
+		data, _, err = preprocess(data, opts, &geo)
+		if err != nil {
+			return fmt.Errorf("preprocessing for tiling: %w", err)
+		}
+	}
+	tiles, err := tileImage(data, opts)
+	if err != nil {
+		return fmt.Errorf("tiling: %w", err)
+	}
+	page.Cols = opts.Tiles.Cols
+	page.Rows = opts.Tiles.Rows
+	page.Tiles = make([]string, len(tiles))
+	for j, t := range tiles {
+		page.Tiles[j] = base64.StdEncoding.EncodeToString(t)
+		name := fmt.Sprintf("%s-tile-r%dc%d%s", trimExt(p.Name), j/opts.Tiles.Cols, j%opts.Tiles.Cols, opts.Ext())
+		if err := writeDebugImage(debugDir, name, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PdftoppmError is a failure to run pdftoppm. Err is an *exec.ExitError when
+// pdftoppm ran and failed, and wraps exec.ErrNotFound when it is not installed.
+type PdftoppmError struct {
+	Err    error
+	Output string
+}
+
+func (e *PdftoppmError) Error() string {
+	return fmt.Sprintf("running pdftoppm: %v: %s", e.Err, e.Output)
+}
+
+func (e *PdftoppmError) Unwrap() error {
+	return e.Err
+}
+
+// rasterizePDF runs pdftoppm on pdfPath and returns the page images in page order.
+func rasterizePDF(pdfPath string, opts Options) ([]rasterPage, error) {
 	tmpDir, err := os.MkdirTemp("", "pdf-convert-*")
 	if err != nil {
 		return nil, fmt.Errorf("creating temp dir: %w", err)
@@ -21,52 +146,82 @@ func PDFToBase64Images(pdfPath, debugDir string) ([]string, error) {
 	defer os.RemoveAll(tmpDir)
 
 	outputPrefix := filepath.Join(tmpDir, "page")
-	cmd := exec.Command("pdftoppm",
-		"-jpeg", "-jpegopt", "quality=80",
-		"-gray",
-		"-scale-to", "768",
-		pdfPath, outputPrefix,
-	)
+	args := append(opts.pdftoppmArgs(), pdfPath, outputPrefix)
+	cmd := exec.Command("pdftoppm", args...)
 	if output, err := cmd.CombinedOutput(); err != nil {
-		return nil, fmt.Errorf("running pdftoppm: %w: %s", err, string(output))
+		return nil, &PdftoppmError{Err: err, Output: string(output)}
 	}
 
-	matches, err := filepath.Glob(outputPrefix + "-*.jpg")
+	matches, err := filepath.Glob(outputPrefix + "-*" + opts.Ext())
 	if err != nil {
 		return nil, fmt.Errorf("globbing output files: %w", err)
 	}
 	sort.Strings(matches)
 
-	if debugDir != "" {
-		if err := os.MkdirAll(debugDir, 0o755); err != nil {
-			return nil, fmt.Errorf("creating debug dir: %w", err)
-		}
-	}
-
-	images := make([]string, 0, len(matches))
+	pages := make([]rasterPage, 0, len(matches))
 	for _, path := range matches {
 		data, err := os.ReadFile(path)
 		if err != nil {
 			return nil, fmt.Errorf("reading %s: %w", path, err)
 		}
-		images = append(images, base64.StdEncoding.EncodeToString(data))
+		pages = append(pages, rasterPage{Name: filepath.Base(path), Data: data})
+	}
 
-		if debugDir != "" {
-			debugPath := filepath.Join(debugDir, filepath.Base(path))
-			if err := os.WriteFile(debugPath, data, 0o644); err != nil {
-				return nil, fmt.Errorf("writing debug image %s: %w", debugPath, err)
-			}
-		}
+	return pages, nil
+}
+
+// preprocessPage applies opts.Preprocess to a rendered page, saving the result next
+// to the original in debugDir with a "-preprocessed" suffix so the two can be compared.
+func preprocessPage(p rasterPage, debugDir string, opts Options) ([]byte, geometry, error) {
+	if !opts.Preprocess.Enabled() {
+		return p.Data, geometry{}, nil
+	}
+	data, geo, err := preprocess(p.Data, opts, nil)
+	if err != nil {
+		return nil, geometry{}, fmt.Errorf("preprocessing %s: %w", p.Name, err)
 	}
+	if err := writeDebugImage(debugDir, trimExt(p.Name)+"-preprocessed"+opts.Ext(), data); err != nil {
+		return nil, geometry{}, err
+	}
+	return data, geo, nil
+}
 
-	return images, nil
+// writeDebugImage saves data as name inside debugDir. It is a no-op when debugDir is empty.
+func writeDebugImage(debugDir, name string, data []byte) error {
+	if debugDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(debugDir, 0o755); err != nil {
+		return fmt.Errorf("creating debug dir: %w", err)
+	}
+	debugPath := filepath.Join(debugDir, name)
+	if err := os.WriteFile(debugPath, data, 0o644); err != nil {
+		return fmt.Errorf("writing debug image %s: %w", debugPath, err)
+	}
+	return nil
+}
+
+func trimExt(name string) string {
+	return name[:len(name)-len(filepath.Ext(name))]
 }
 
-// ImageToBase64 reads an image file and returns its base64-encoded content.
-func ImageToBase64(imagePath string) (string, error) {
+// ImageToBase64 reads an image file and returns it base64-encoded after normalizing
+// its orientation, size and encoding to match opts. Multi-page images return the first page.
+func ImageToBase64(imagePath, debugDir string, opts Options) (string, error) {
 	data, err := os.ReadFile(imagePath)
 	if err != nil {
 		return "", fmt.Errorf("reading image %s: %w", imagePath, err)
 	}
-	return base64.StdEncoding.EncodeToString(data), nil
+	fileType, err := DetectType(data)
+	if err != nil {
+		return "", err
+	}
+	if fileType == TypePDF {
+		return "", fmt.Errorf("%s is a PDF, not an image", imagePath)
+	}
+	pages, err := imageToPages(fileType, data, debugDir, opts)
+	if err != nil {
+		return "", err
+	}
+	return pages[0].Image, nil
 }
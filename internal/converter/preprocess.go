@@ -0,0 +1,631 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"math"
+	"sort"
+	"strings"
+)
+
+// PreprocessOptions controls the optional cleanup applied to page images after
+// rasterization and before encoding. Steps run in the order listed.
+type PreprocessOptions struct {
+	// Crop trims uniform borders and background around the page content.
+	Crop bool
+	// Rotate detects pages turned by 90, 180 or 270 degrees and turns them upright.
+	// It relies on the text lines of the page, so it is not part of "all".
+	Rotate bool
+	// Deskew straightens pages tilted by up to maxSkewDegrees.
+	Deskew bool
+	// Contrast stretches the luminance range so text is close to black and paper close to white.
+	Contrast bool
+	// Binarize converts the page to pure black and white using Otsu's threshold.
+	Binarize bool
+}
+
+// Enabled reports whether any preprocessing step is turned on.
+func (p PreprocessOptions) Enabled() bool {
+	return p.Crop || p.Rotate || p.Deskew || p.Contrast || p.Binarize
+}
+
+// ParsePreprocess parses a comma-separated list of preprocessing steps:
+// crop, rotate, deskew, contrast, binarize, or "all" for crop, deskew and contrast.
+func ParsePreprocess(s string) (PreprocessOptions, error) {
+	var p PreprocessOptions
+	for _, step := range strings.Split(s, ",") {
+		switch strings.ToLower(strings.TrimSpace(step)) {
+		case "":
+		case "all":
+			p.Crop, p.Deskew, p.Contrast = true, true, true
+		case "crop":
+			p.Crop = true
+		case "rotate":
+			p.Rotate = true
+		case "deskew":
+			p.Deskew = true
+		case "contrast":
+			p.Contrast = true
+		case "binarize":
+			p.Binarize = true
+		default:
+			return PreprocessOptions{}, fmt.Errorf("unknown preprocessing step %q", step)
+		}
+	}
+	return p, nil
+}
+
+const (
+	// inkThreshold is the minimum luminance difference from the background for a pixel to count as content.
+	inkThreshold = 48
+	// maxSkewDegrees is the largest tilt Deskew searches for.
+	maxSkewDegrees = 6.0
+	// skewStepDegrees is the angular resolution of the deskew search.
+	skewStepDegrees = 0.2
+	// maxSkewSamples caps the number of ink pixels used to score each deskew angle.
+	maxSkewSamples = 200_000
+	// minRotateLines is the fewest text lines Rotate needs before turning a page.
+	minRotateLines = 3
+)
+
+// Preprocess decodes an encoded page image, applies the steps enabled in
+// opts.Preprocess and re-encodes it in opts.Format.
+func Preprocess(data []byte, opts Options) ([]byte, error) {
+	out, _, err := preprocess(data, opts, nil)
+	return out, err
+}
+
+// preprocess is Preprocess that also returns the page geometry it corrected.
+// When geo is not nil it is applied instead of being detected from the image,
+// so a second render of the same page is cropped, rotated and deskewed alike.
+func preprocess(data []byte, opts Options, geo *geometry) ([]byte, geometry, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, geometry{}, fmt.Errorf("decoding image: %w", err)
+	}
+	var applied geometry
+	if geo != nil {
+		img, applied = geo.apply(img), *geo
+	} else {
+		img, applied = correctGeometry(img, opts.Preprocess)
+	}
+	img = preprocessImage(img, opts.Preprocess)
+	out, err := encodeImage(img, opts)
+	if err != nil {
+		return nil, geometry{}, fmt.Errorf("encoding preprocessed image: %w", err)
+	}
+	return out, applied, nil
+}
+
+// preprocessImage applies the steps that depend only on pixel values, after the
+// geometry has been corrected.
+func preprocessImage(img image.Image, p PreprocessOptions) image.Image {
+	if p.Contrast {
+		img = stretchContrast(img)
+	}
+	if p.Binarize {
+		img = binarize(img)
+	}
+	return img
+}
+
+// geometry is the crop, rotation and skew correction of a page. The crop is
+// kept as fractions of the page size so it also fits renders at other resolutions.
+type geometry struct {
+	// cropped is set when crop holds the content area as left, top, right and
+	// bottom fractions of the width and height.
+	cropped bool
+	crop    [4]float64
+	// turns is the number of clockwise quarter turns that make the page upright.
+	turns int
+	// skew is the angle in radians rotated away by Deskew.
+	skew float64
+}
+
+// correctGeometry crops, rotates and deskews img as enabled in p, and returns
+// the corrected image with the geometry it found.
+func correctGeometry(img image.Image, p PreprocessOptions) (image.Image, geometry) {
+	var geo geometry
+	if p.Crop {
+		if rect, ok := contentRect(img); ok {
+			b := img.Bounds()
+			w, h := float64(b.Dx()), float64(b.Dy())
+			r := rect.Sub(b.Min)
+			geo.cropped = true
+			geo.crop = [4]float64{float64(r.Min.X) / w, float64(r.Min.Y) / h, float64(r.Max.X) / w, float64(r.Max.Y) / h}
+			img = cloneRect(img, rect)
+		}
+	}
+	if p.Rotate {
+		geo.turns = uprightTurns(img)
+		img = turn(img, geo.turns)
+	}
+	if p.Deskew {
+		g := toGray(img)
+		bg := background(g)
+		geo.skew = skewAngle(g, bg)
+		if geo.skew != 0 {
+			img = rotateSmall(img, geo.skew, color.Gray{Y: bg})
+		}
+	}
+	return img, geo
+}
+
+// apply crops, rotates and deskews img by geo, scaling the crop to img's size.
+func (geo geometry) apply(img image.Image) image.Image {
+	if geo.cropped {
+		b := img.Bounds()
+		w, h := float64(b.Dx()), float64(b.Dy())
+		rect := image.Rect(
+			int(math.Round(geo.crop[0]*w)),
+			int(math.Round(geo.crop[1]*h)),
+			int(math.Round(geo.crop[2]*w)),
+			int(math.Round(geo.crop[3]*h)),
+		).Add(b.Min).Intersect(b)
+		img = cloneRect(img, rect)
+	}
+	img = turn(img, geo.turns)
+	if geo.skew != 0 {
+		img = rotateSmall(img, geo.skew, color.Gray{Y: background(toGray(img))})
+	}
+	return img
+}
+
+// contentRect returns the area of img inside the rows and columns at the edges
+// that contain (almost) no content, plus a small margin. It reports false for
+// pages that appear blank.
+func contentRect(img image.Image) (image.Rectangle, bool) {
+	g := toGray(img)
+	bg := background(g)
+	b := g.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	rowInk := make([]int, h)
+	colInk := make([]int, w)
+	for y := 0; y < h; y++ {
+		row := g.Pix[y*g.Stride : y*g.Stride+w]
+		for x, v := range row {
+			if isInk(v, bg) {
+				rowInk[y]++
+				colInk[x]++
+			}
+		}
+	}
+
+	top, bottom := contentSpan(rowInk, w/200+1)
+	left, right := contentSpan(colInk, h/200+1)
+	if top < 0 || left < 0 || (bottom-top)*(right-left) < w*h/10 {
+		return image.Rectangle{}, false
+	}
+
+	marginX, marginY := w/100, h/100
+	rect := image.Rect(
+		max(left-marginX, 0),
+		max(top-marginY, 0),
+		min(right+marginX, w),
+		min(bottom+marginY, h),
+	).Add(img.Bounds().Min)
+	return rect, true
+}
+
+// contentSpan returns the first and one-past-last indexes whose count reaches minCount,
+// or -1, -1 if none do.
+func contentSpan(counts []int, minCount int) (int, int) {
+	first, last := -1, -1
+	for i, n := range counts {
+		if n >= minCount {
+			if first < 0 {
+				first = i
+			}
+			last = i + 1
+		}
+	}
+	return first, last
+}
+
+// uprightTurns returns the clockwise quarter turns that make img upright, or 0
+// when the evidence is weak.
+//
+// Text lines alternate with blank gaps, so on a sideways page the column profile
+// has many more ink runs than the row profile. Tables and multi-column layouts
+// alternate in both directions, so the columns must also have at least three
+// times as many runs and twice the variation. Latin text has more ascenders than
+// descenders, so ink concentrated below the x-height band of each line means the
+// page is upside down; unlike line ends, this does not depend on alignment.
+func uprightTurns(img image.Image) int {
+	g := toGray(img)
+	bg := background(g)
+	b := g.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	turns := 0
+	rows, cols := inkProfile(g, bg, false), inkProfile(g, bg, true)
+	rowRuns := inkRuns(rows, w/200+1, h/300+2)
+	colRuns := inkRuns(cols, h/200+1, w/300+2)
+	if len(colRuns) >= minRotateLines && len(colRuns) >= 3*len(rowRuns) && profileCV(cols) > 2*profileCV(rows) {
+		turns = 1
+		g = toGray(rotate90(g))
+	}
+	if upsideDown(g, bg) {
+		turns += 2
+	}
+	return turns
+}
+
+// upsideDown reports whether the text lines of g have clearly more ink below
+// their x-height band than above it.
+func upsideDown(g *image.Gray, bg uint8) bool {
+	rows := inkProfile(g, bg, false)
+	var above, below, lines int
+	for _, run := range inkRuns(rows, g.Bounds().Dx()/200+1, 1) {
+		if run[1]-run[0] < 4 {
+			continue
+		}
+		line := rows[run[0]:run[1]]
+		peak := 0
+		for _, n := range line {
+			peak = max(peak, n)
+		}
+		top, bottom := contentSpan(line, (peak+1)/2)
+		for _, n := range line[:top] {
+			above += n
+		}
+		for _, n := range line[bottom:] {
+			below += n
+		}
+		lines++
+	}
+	return lines >= minRotateLines && float64(below) > 1.5*float64(above)
+}
+
+// inkRuns returns the [start, end) spans of a profile whose count reaches
+// minCount, merging spans separated by fewer than minGap entries.
+func inkRuns(profile []int, minCount, minGap int) [][2]int {
+	var runs [][2]int
+	for i, n := range profile {
+		if n < minCount {
+			continue
+		}
+		if len(runs) > 0 && i-runs[len(runs)-1][1] < minGap {
+			runs[len(runs)-1][1] = i + 1
+		} else {
+			runs = append(runs, [2]int{i, i + 1})
+		}
+	}
+	return runs
+}
+
+// inkProfile counts content pixels per row, or per column when byColumn is set.
+func inkProfile(g *image.Gray, bg uint8, byColumn bool) []int {
+	b := g.Bounds()
+	w, h := b.Dx(), b.Dy()
+	n := h
+	if byColumn {
+		n = w
+	}
+	profile := make([]int, n)
+	for y := 0; y < h; y++ {
+		row := g.Pix[y*g.Stride : y*g.Stride+w]
+		for x, v := range row {
+			if isInk(v, bg) {
+				if byColumn {
+					profile[x]++
+				} else {
+					profile[y]++
+				}
+			}
+		}
+	}
+	return profile
+}
+
+// profileCV is the coefficient of variation (stddev / mean) of a projection profile.
+func profileCV(profile []int) float64 {
+	values := make([]float64, len(profile))
+	var sum float64
+	for i, v := range profile {
+		values[i] = float64(v)
+		sum += values[i]
+	}
+	if sum == 0 {
+		return 0
+	}
+	return stddev(values) / (sum / float64(len(values)))
+}
+
+func stddev(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sq / float64(len(values)))
+}
+
+// skewAngle finds the small tilt angle, in radians, that maximizes the sharpness
+// of the row projection profile of content pixels. It returns 0 for pages that
+// are straight or blank.
+func skewAngle(g *image.Gray, bg uint8) float64 {
+	b := g.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// Sample on a coarse grid so large renders don't allocate a point per ink pixel
+	step := int(math.Sqrt(float64(w*h)/(maxSkewSamples*10))) + 1
+	var xs, ys []float64
+	for y := 0; y < h; y += step {
+		row := g.Pix[y*g.Stride : y*g.Stride+w]
+		for x := 0; x < w; x += step {
+			if isInk(row[x], bg) {
+				xs = append(xs, float64(x))
+				ys = append(ys, float64(y))
+			}
+		}
+	}
+	if len(xs) == 0 {
+		return 0
+	}
+	stride := len(xs)/maxSkewSamples + 1
+
+	diag := int(math.Hypot(float64(w), float64(h))) + 1
+	bins := make([]float64, 2*diag+1)
+	bestAngle, bestScore := 0.0, -1.0
+	for deg := -maxSkewDegrees; deg <= maxSkewDegrees+1e-9; deg += skewStepDegrees {
+		rad := deg * math.Pi / 180
+		sin, cos := math.Sincos(rad)
+		clear(bins)
+		for i := 0; i < len(xs); i += stride {
+			bins[int(ys[i]*cos-xs[i]*sin)+diag]++
+		}
+		var score float64
+		for i := 1; i < len(bins); i++ {
+			d := bins[i] - bins[i-1]
+			score += d * d
+		}
+		if score > bestScore {
+			bestScore, bestAngle = score, deg
+		}
+	}
+
+	if math.Abs(bestAngle) < skewStepDegrees/2 {
+		return 0
+	}
+	return bestAngle * math.Pi / 180
+}
+
+// rotateSmall resamples img so that content lying along angle rad becomes horizontal.
+// Areas outside the source are filled with fill.
+func rotateSmall(img image.Image, rad float64, fill color.Color) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dst := newLike(img, w, h)
+	sin, cos := math.Sincos(rad)
+	cx, cy := float64(w)/2, float64(h)/2
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := float64(x)-cx, float64(y)-cy
+			sx := dx*cos - dy*sin + cx
+			sy := dx*sin + dy*cos + cy
+			dst.Set(x, y, bilinear(img, sx+float64(b.Min.X), sy+float64(b.Min.Y), fill))
+		}
+	}
+	return dst
+}
+
+// bilinear samples img at a fractional position, returning fill outside its bounds.
+func bilinear(img image.Image, fx, fy float64, fill color.Color) color.Color {
+	b := img.Bounds()
+	x0, y0 := int(math.Floor(fx)), int(math.Floor(fy))
+	if x0 < b.Min.X || y0 < b.Min.Y || x0+1 >= b.Max.X || y0+1 >= b.Max.Y {
+		return fill
+	}
+	tx, ty := fx-float64(x0), fy-float64(y0)
+
+	var out [4]float64
+	for _, s := range []struct {
+		x, y int
+		wgt  float64
+	}{
+		{x0, y0, (1 - tx) * (1 - ty)},
+		{x0 + 1, y0, tx * (1 - ty)},
+		{x0, y0 + 1, (1 - tx) * ty},
+		{x0 + 1, y0 + 1, tx * ty},
+	} {
+		r, g, bl, a := img.At(s.x, s.y).RGBA()
+		out[0] += float64(r) * s.wgt
+		out[1] += float64(g) * s.wgt
+		out[2] += float64(bl) * s.wgt
+		out[3] += float64(a) * s.wgt
+	}
+	return color.RGBA64{uint16(out[0]), uint16(out[1]), uint16(out[2]), uint16(out[3])}
+}
+
+// stretchContrast maps the 1st..99th luminance percentiles to the full 0..255 range.
+func stretchContrast(img image.Image) image.Image {
+	g := toGray(img)
+	var hist [256]int
+	for _, v := range g.Pix {
+		hist[v]++
+	}
+	lo, hi := percentile(hist, len(g.Pix), 0.01), percentile(hist, len(g.Pix), 0.99)
+	if hi-lo < 16 {
+		return img
+	}
+
+	var lut [256]uint8
+	for i := range lut {
+		v := (i - lo) * 255 / (hi - lo)
+		lut[i] = uint8(min(max(v, 0), 255))
+	}
+
+	b := img.Bounds()
+	dst := newLike(img, b.Dx(), b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			dst.Set(x-b.Min.X, y-b.Min.Y, color.NRGBA{lut[c.R], lut[c.G], lut[c.B], c.A})
+		}
+	}
+	return dst
+}
+
+func percentile(hist [256]int, total int, p float64) int {
+	target := int(float64(total) * p)
+	sum := 0
+	for i, n := range hist {
+		sum += n
+		if sum > target {
+			return i
+		}
+	}
+	return 255
+}
+
+// binarize converts img to black and white using Otsu's threshold.
+func binarize(img image.Image) image.Image {
+	g := toGray(img)
+	var hist [256]int
+	for _, v := range g.Pix {
+		hist[v]++
+	}
+	t := otsuThreshold(hist, len(g.Pix))
+
+	b := g.Bounds()
+	dst := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		src := g.Pix[y*g.Stride : y*g.Stride+b.Dx()]
+		out := dst.Pix[y*dst.Stride : y*dst.Stride+b.Dx()]
+		for x, v := range src {
+			if int(v) > t {
+				out[x] = 255
+			}
+		}
+	}
+	return dst
+}
+
+func otsuThreshold(hist [256]int, total int) int {
+	var sumAll float64
+	for i, n := range hist {
+		sumAll += float64(i * n)
+	}
+	var sumB, wB float64
+	best, threshold := 0.0, 127
+	for t, n := range hist {
+		wB += float64(n)
+		if wB == 0 {
+			continue
+		}
+		wF := float64(total) - wB
+		if wF == 0 {
+			break
+		}
+		sumB += float64(t * n)
+		mB := sumB / wB
+		mF := (sumAll - sumB) / wF
+		between := wB * wF * (mB - mF) * (mB - mF)
+		if between > best {
+			best, threshold = between, t
+		}
+	}
+	return threshold
+}
+
+// background estimates the paper color as the median luminance of the outermost pixels.
+func background(g *image.Gray) uint8 {
+	b := g.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return 255
+	}
+	var edge []uint8
+	for x := 0; x < w; x++ {
+		edge = append(edge, g.Pix[x], g.Pix[(h-1)*g.Stride+x])
+	}
+	for y := 0; y < h; y++ {
+		edge = append(edge, g.Pix[y*g.Stride], g.Pix[y*g.Stride+w-1])
+	}
+	sort.Slice(edge, func(i, j int) bool { return edge[i] < edge[j] })
+	return edge[len(edge)/2]
+}
+
+func isInk(v, bg uint8) bool {
+	d := int(v) - int(bg)
+	return d > inkThreshold || d < -inkThreshold
+}
+
+// toGray returns img as an *image.Gray with a zero origin, converting if necessary.
+func toGray(img image.Image) *image.Gray {
+	b := img.Bounds()
+	if g, ok := img.(*image.Gray); ok && b.Min == (image.Point{}) {
+		return g
+	}
+	g := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(g, g.Bounds(), img, b.Min, draw.Src)
+	return g
+}
+
+// newLike allocates a w x h image with a zero origin, grayscale if img is grayscale.
+func newLike(img image.Image, w, h int) draw.Image {
+	if _, ok := img.(*image.Gray); ok {
+		return image.NewGray(image.Rect(0, 0, w, h))
+	}
+	return image.NewRGBA(image.Rect(0, 0, w, h))
+}
+
+// cloneRect copies rect out of img into a new image with a zero origin.
+func cloneRect(img image.Image, rect image.Rectangle) image.Image {
+	dst := newLike(img, rect.Dx(), rect.Dy())
+	draw.Draw(dst, dst.Bounds(), img, rect.Min, draw.Src)
+	return dst
+}
+
+// rotate90 turns img 90 degrees clockwise.
+func rotate90(img image.Image) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dst := newLike(img, h, w)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dst.Set(h-1-y, x, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// turn rotates img by n clockwise quarter turns.
+func turn(img image.Image, n int) image.Image {
+	switch n % 4 {
+	case 1:
+		return rotate90(img)
+	case 2:
+		return rotate180(img)
+	case 3:
+		return rotate180(rotate90(img))
+	}
+	return img
+}
+
+// rotate180 turns img upside down.
+func rotate180(img image.Image) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dst := newLike(img, w, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dst.Set(w-1-x, h-1-y, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
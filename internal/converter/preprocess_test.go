@@ -0,0 +1,164 @@
+package converter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+var sampleText = []string{
+	"Invoice number 20931 dated the fourth of March",
+	"Please pay the total amount within thirty days",
+	"Bank transfer to the account shown below",
+	"Questions about this bill can be sent by email",
+	"Thank you for your business and have a good day",
+	"Reference your customer number with each payment",
+	"Late payments may be charged a monthly fee",
+	"Keep this document for your tax records",
+}
+
+const (
+	pageW      = 480
+	pageH      = 360
+	lineHeight = 20
+)
+
+// newPage returns a white page.
+func newPage() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, pageW, pageH))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	return img
+}
+
+// drawText draws s with its baseline at (x, y).
+func drawText(img draw.Image, x, y int, s string) {
+	d := font.Drawer{Dst: img, Src: image.Black, Face: basicfont.Face7x13, Dot: fixed.P(x, y)}
+	d.DrawString(s)
+}
+
+// textPage draws sampleText left-aligned, or right-aligned with a ragged left edge.
+func textPage(rightAligned bool) *image.Gray {
+	img := newPage()
+	for i, line := range sampleText {
+		x := 40
+		if rightAligned {
+			x = pageW - 40 - font.MeasureString(basicfont.Face7x13, line).Round()
+		}
+		drawText(img, x, 60+i*lineHeight, line)
+	}
+	return img
+}
+
+// tablePage draws a ruled table of short cells.
+func tablePage() *image.Gray {
+	img := newPage()
+	cells := [][]string{
+		{"Date", "Item", "Qty", "Amount"},
+		{"03/04", "Paper", "12", "48.00"},
+		{"03/09", "Toner", "2", "139.90"},
+		{"03/15", "Folders", "40", "22.40"},
+		{"03/22", "Stamps", "100", "68.00"},
+		{"03/30", "Labels", "6", "17.70"},
+	}
+	left, top, colW, rowH := 40, 40, 100, 40
+	for r := 0; r <= len(cells); r++ {
+		draw.Draw(img, image.Rect(left, top+r*rowH, left+4*colW+1, top+r*rowH+1), image.Black, image.Point{}, draw.Src)
+	}
+	for c := 0; c <= 4; c++ {
+		draw.Draw(img, image.Rect(left+c*colW, top, left+c*colW+1, top+len(cells)*rowH+1), image.Black, image.Point{}, draw.Src)
+	}
+	for r, row := range cells {
+		for c, cell := range row {
+			drawText(img, left+c*colW+8, top+r*rowH+25, cell)
+		}
+	}
+	return img
+}
+
+func TestUprightTurnsLeavesUprightPages(t *testing.T) {
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"left-aligned text", textPage(false)},
+		{"right-aligned text", textPage(true)},
+		{"table", tablePage()},
+		{"blank", newPage()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := uprightTurns(tt.img); got != 0 {
+				t.Errorf("uprightTurns = %d, want 0", got)
+			}
+		})
+	}
+}
+
+func TestUprightTurnsCorrectsRotatedText(t *testing.T) {
+	for _, rightAligned := range []bool{false, true} {
+		page := textPage(rightAligned)
+		for n := 1; n <= 3; n++ {
+			if got, want := uprightTurns(turn(page, n)), 4-n; got != want {
+				t.Errorf("rightAligned=%v: page turned %d quarter turns: uprightTurns = %d, want %d", rightAligned, n, got, want)
+			}
+		}
+	}
+}
+
+func TestGeometryAppliesToLargerRender(t *testing.T) {
+	page := textPage(false)
+	corrected, geo := correctGeometry(turn(page, 2), PreprocessOptions{Crop: true, Rotate: true, Deskew: true})
+	if !geo.cropped || geo.turns != 2 {
+		t.Fatalf("geometry = %+v, want cropped and 2 turns", geo)
+	}
+
+	// A render at twice the resolution
+	large := image.NewGray(image.Rect(0, 0, 2*pageW, 2*pageH))
+	for y := 0; y < 2*pageH; y++ {
+		for x := 0; x < 2*pageW; x++ {
+			large.SetGray(x, y, color.Gray{Y: page.GrayAt(x/2, y/2).Y})
+		}
+	}
+	got := geo.apply(turn(large, 2)).Bounds()
+	want := corrected.Bounds()
+	if abs(got.Dx()-2*want.Dx()) > 2 || abs(got.Dy()-2*want.Dy()) > 2 {
+		t.Errorf("large render corrected to %dx%d, want about %dx%d", got.Dx(), got.Dy(), 2*want.Dx(), 2*want.Dy())
+	}
+}
+
+func TestParsePreprocess(t *testing.T) {
+	tests := []struct {
+		in   string
+		want PreprocessOptions
+	}{
+		{"", PreprocessOptions{}},
+		{"all", PreprocessOptions{Crop: true, Deskew: true, Contrast: true}},
+		{"all,rotate", PreprocessOptions{Crop: true, Rotate: true, Deskew: true, Contrast: true}},
+		{" Crop , binarize", PreprocessOptions{Crop: true, Binarize: true}},
+	}
+	for _, tt := range tests {
+		got, err := ParsePreprocess(tt.in)
+		if err != nil {
+			t.Errorf("ParsePreprocess(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParsePreprocess(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+	if _, err := ParsePreprocess("sharpen"); err == nil {
+		t.Error("ParsePreprocess(\"sharpen\") succeeded, want error")
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,150 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// Page is a single rasterized document page.
+type Page struct {
+	// Image is the base64-encoded whole page at the configured max dimension.
+	Image string
+	// Tiles holds base64-encoded overlapping crops of the page in row-major order.
+	// It is empty when tiling is disabled.
+	Tiles []string
+	// Cols and Rows describe the tile grid.
+	Cols int
+	Rows int
+}
+
+// TileOptions controls splitting pages into overlapping tiles for high-resolution transcription.
+type TileOptions struct {
+	// Cols and Rows define the grid. Tiling is disabled unless Cols*Rows > 1.
+	Cols int
+	Rows int
+	// Overlap is the fraction of a tile's width/height shared with each neighbour (0-0.5).
+	Overlap float64
+	// MaxDimension caps the long edge of the page before it is split. 0 means
+	// the page max dimension multiplied by the larger of Cols and Rows.
+	MaxDimension int
+}
+
+// Enabled reports whether the grid splits pages into more than one tile.
+func (t TileOptions) Enabled() bool {
+	return t.Cols > 0 && t.Rows > 0 && t.Cols*t.Rows > 1
+}
+
+// Validate reports whether the tile options are usable.
+func (t TileOptions) Validate() error {
+	if t.Cols < 0 || t.Rows < 0 {
+		return fmt.Errorf("invalid tile grid %dx%d", t.Cols, t.Rows)
+	}
+	if t.Overlap < 0 || t.Overlap > 0.5 {
+		return fmt.Errorf("invalid tile overlap %v (must be 0-0.5)", t.Overlap)
+	}
+	if t.MaxDimension < 0 {
+		return fmt.Errorf("invalid tile max dimension %d", t.MaxDimension)
+	}
+	return nil
+}
+
+func (t TileOptions) maxDimension(pageMax int) int {
+	if t.MaxDimension > 0 {
+		return t.MaxDimension
+	}
+	if pageMax == 0 {
+		return 0
+	}
+	return pageMax * max(t.Cols, t.Rows)
+}
+
+// ParseGrid parses a tile grid in "COLSxROWS" form, e.g. "2x3".
+func ParseGrid(s string) (cols, rows int, err error) {
+	c, r, ok := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid tile grid %q (expected COLSxROWS)", s)
+	}
+	cols, err = strconv.Atoi(c)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid tile grid %q: %w", s, err)
+	}
+	rows, err = strconv.Atoi(r)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid tile grid %q: %w", s, err)
+	}
+	if cols < 1 || rows < 1 {
+		return 0, 0, fmt.Errorf("invalid tile grid %q", s)
+	}
+	return cols, rows, nil
+}
+
+// tileImage splits an encoded page image into opts.Tiles.Cols x opts.Tiles.Rows
+// overlapping crops, re-encoded in opts.Format. Tiles are returned in row-major order.
+func tileImage(data []byte, opts Options) ([][]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decoding page image: %w", err)
+	}
+
+	sub, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if !ok {
+		return nil, fmt.Errorf("image type %T does not support cropping", img)
+	}
+
+	cols, rows := opts.Tiles.Cols, opts.Tiles.Rows
+	b := img.Bounds()
+	tileW := b.Dx() / cols
+	tileH := b.Dy() / rows
+	padX := int(float64(tileW) * opts.Tiles.Overlap)
+	padY := int(float64(tileH) * opts.Tiles.Overlap)
+
+	tiles := make([][]byte, 0, cols*rows)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			rect := image.Rect(
+				b.Min.X+c*tileW-padX,
+				b.Min.Y+r*tileH-padY,
+				b.Min.X+(c+1)*tileW+padX,
+				b.Min.Y+(r+1)*tileH+padY,
+			)
+			if c == cols-1 {
+				rect.Max.X = b.Max.X
+			}
+			if r == rows-1 {
+				rect.Max.Y = b.Max.Y
+			}
+			rect = rect.Intersect(b)
+
+			encoded, err := encodeImage(sub.SubImage(rect), opts)
+			if err != nil {
+				return nil, fmt.Errorf("encoding tile r%dc%d: %w", r, c, err)
+			}
+			tiles = append(tiles, encoded)
+		}
+	}
+
+	return tiles, nil
+}
+
+// encodeImage encodes img in the format and quality given by opts.
+func encodeImage(img image.Image, opts Options) ([]byte, error) {
+	var buf bytes.Buffer
+	switch opts.Format {
+	case FormatPNG:
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+	default:
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: opts.Quality}); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
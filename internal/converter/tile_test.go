@@ -0,0 +1,30 @@
+package converter
+
+import "testing"
+
+func TestParseGrid(t *testing.T) {
+	tests := []struct {
+		in         string
+		cols, rows int
+		wantErr    bool
+	}{
+		{in: "2x2", cols: 2, rows: 2},
+		{in: "1x3", cols: 1, rows: 3},
+		{in: " 3X1 ", cols: 3, rows: 1},
+		{in: "2", wantErr: true},
+		{in: "0x2", wantErr: true},
+		{in: "2x-1", wantErr: true},
+		{in: "ax2", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		cols, rows, err := ParseGrid(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseGrid(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if cols != tt.cols || rows != tt.rows {
+			t.Errorf("ParseGrid(%q) = %dx%d, want %dx%d", tt.in, cols, rows, tt.cols, tt.rows)
+		}
+	}
+}
@@ -1,48 +1,55 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/bartlettc22/paperless-llm-processor/api"
+	"github.com/bartlettc22/paperless-llm-processor/internal/apierror"
+	"github.com/bartlettc22/paperless-llm-processor/internal/auth"
 	"github.com/bartlettc22/paperless-llm-processor/internal/converter"
+	"github.com/bartlettc22/paperless-llm-processor/internal/jobs"
+	"github.com/bartlettc22/paperless-llm-processor/internal/logging"
+	"github.com/bartlettc22/paperless-llm-processor/internal/metrics"
 	"github.com/bartlettc22/paperless-llm-processor/internal/ollama"
+	"github.com/bartlettc22/paperless-llm-processor/internal/paperless"
+	"github.com/bartlettc22/paperless-llm-processor/internal/pipeline"
 )
 
 type AnalyzeHandler struct {
 	Client   *ollama.Client
 	DebugDir string
-}
-
-type analyzeResponse struct {
-	Filename string         `json:"filename"`
-	Pages    []pageResponse `json:"pages"`
-}
-
-type pageResponse struct {
-	Page     int    `json:"page"`
-	Analysis string `json:"analysis"`
+	Raster   converter.Options
+	// Jobs runs analyses in the background. When nil, or when the request sets
+	// wait=true, the analysis runs within the request.
+	Jobs *jobs.Queue
+	// Paperless supplies the document types for structured analysis. When nil,
+	// they must be given in the request.
+	Paperless *paperless.Client
 }
 
 func (h *AnalyzeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		apierror.Write(w, r, apierror.MethodNotAllowed)
 		return
 	}
 
 	if err := r.ParseMultipartForm(100 << 20); err != nil { // 100 MB max
-		http.Error(w, "failed to parse form: "+err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid("failed to parse form: "+err.Error()))
 		return
 	}
 
 	file, header, err := r.FormFile("file")
 	if err != nil {
-		http.Error(w, "missing 'file' field: "+err.Error(), http.StatusBadRequest)
+		apierror.Write(w, r, apierror.Invalid("missing 'file' field: "+err.Error()))
 		return
 	}
 	defer file.Close()
@@ -52,68 +59,206 @@ func (h *AnalyzeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		prompt = "Describe the contents of this document in detail."
 	}
 
-	tmpFile, err := os.CreateTemp("", "upload-*"+filepath.Ext(header.Filename))
+	data, err := io.ReadAll(file)
 	if err != nil {
-		http.Error(w, "failed to create temp file", http.StatusInternalServerError)
+		apierror.Write(w, r, fmt.Errorf("reading uploaded file: %w", err))
 		return
 	}
-	defer os.Remove(tmpFile.Name())
-	defer tmpFile.Close()
 
-	if _, err := io.Copy(tmpFile, file); err != nil {
-		http.Error(w, "failed to save uploaded file", http.StatusInternalServerError)
+	// Reject unsupported uploads before queuing them
+	if _, err := converter.DetectType(data); err != nil {
+		apierror.Write(w, r, apierror.Invalid(err.Error()))
 		return
 	}
-	tmpFile.Close()
 
-	ext := strings.ToLower(filepath.Ext(header.Filename))
-	var images []string
-
-	switch ext {
-	case ".pdf":
-		images, err = converter.PDFToBase64Images(tmpFile.Name(), h.DebugDir)
+	var work jobs.Func
+	switch mode := r.FormValue("mode"); mode {
+	case "", api.ModeText:
+		work = h.analyze(data, header.Filename, prompt)
+	case api.ModeStructured:
+		docTypes, err := h.documentTypes(r)
 		if err != nil {
-			http.Error(w, "failed to convert PDF: "+err.Error(), http.StatusInternalServerError)
+			apierror.Write(w, r, err)
 			return
 		}
-	case ".png", ".jpg", ".jpeg", ".gif", ".webp":
-		img, err := converter.ImageToBase64(tmpFile.Name())
+		work = h.analyzeStructured(data, header.Filename, ollama.Choices{DocumentTypes: docTypes})
+	default:
+		apierror.Write(w, r, apierror.Invalid(fmt.Sprintf("invalid mode %q (must be text or structured)", mode)))
+		return
+	}
+
+	wait, _ := strconv.ParseBool(r.FormValue("wait"))
+	if h.Jobs == nil || wait {
+		result, err := work(r.Context(), jobs.NewJob())
 		if err != nil {
-			http.Error(w, "failed to read image: "+err.Error(), http.StatusInternalServerError)
+			apierror.Write(w, r, err)
 			return
 		}
-		images = []string{img}
-	default:
-		http.Error(w, fmt.Sprintf("unsupported file type: %s", ext), http.StatusBadRequest)
+		writeJSON(w, http.StatusOK, result)
 		return
 	}
 
-	resp := analyzeResponse{
-		Filename: header.Filename,
-		Pages:    make([]pageResponse, 0, len(images)),
+	job, err := h.Jobs.Submit(auth.Name(r.Context()), background(logging.RequestID(r.Context()), work))
+	if err != nil {
+		apierror.Write(w, r, submitError(err))
+		return
 	}
+	slog.InfoContext(r.Context(), "queued analysis", "filename", header.Filename, logging.KeyRunID, job.ID)
 
-	for i, img := range images {
-		pagePrompt := prompt
-		if len(images) > 1 {
-			pagePrompt = fmt.Sprintf("This is page %d of %d. %s", i+1, len(images), prompt)
+	statusURL := "/jobs/" + job.ID
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", statusURL)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(api.JobResponse{
+		JobID:     job.ID,
+		Status:    api.JobStatus(job.Info().Status),
+		StatusURL: statusURL,
+		ResultURL: statusURL + "/result",
+	})
+}
+
+// analyze returns the work of analyzing an uploaded file page by page.
+func (h *AnalyzeHandler) analyze(data []byte, filename, prompt string) jobs.Func {
+	return func(ctx context.Context, job *jobs.Job) (interface{}, error) {
+		ctx = logging.With(ctx, logging.KeyRunID, job.ID, logging.KeyModel, h.Client.Model)
+		pages, err := h.rasterize(data)
+		if err != nil {
+			return nil, apierror.Conversion("failed to convert file", err)
+		}
+		job.SetPages(len(pages))
+
+		resp := api.AnalyzeResponse{
+			Filename: filename,
+			Pages:    make([]api.PageResponse, 0, len(pages)),
 		}
 
-		log.Printf("Analyzing %s page %d/%d", header.Filename, i+1, len(images))
-		analysis, err := h.Client.Analyze(pagePrompt, []string{img})
+		for i, page := range pages {
+			pagePrompt := prompt
+			if len(pages) > 1 {
+				pagePrompt = fmt.Sprintf("This is page %d of %d. %s", i+1, len(pages), prompt)
+			}
+
+			ctx := logging.With(ctx, logging.KeyPage, i+1)
+			slog.InfoContext(ctx, "analyzing page", "filename", filename, "pages", len(pages))
+			job.StartPage(i)
+			analysis, err := h.Client.Analyze(ctx, pagePrompt, []string{page.Image})
+			job.FinishPage(i, err)
+			if err != nil {
+				countDocument(h.Client.Model, err)
+				return nil, apierror.Upstream(apierror.Ollama, fmt.Sprintf("analysis failed on page %d", i+1), err)
+			}
+			metrics.PagesProcessed.WithLabelValues(h.Client.Model).Inc()
+
+			resp.Pages = append(resp.Pages, api.PageResponse{
+				Page:     i + 1,
+				Analysis: analysis,
+			})
+
+			slog.InfoContext(ctx, "completed page", "filename", filename, "pages", len(pages))
+		}
+
+		countDocument(h.Client.Model, nil)
+		return resp, nil
+	}
+}
+
+// documentTypes returns the document type names to choose from: those in
+// Paperless-ngx when configured, otherwise the request's document_types field
+// (comma-separated or repeated).
+func (h *AnalyzeHandler) documentTypes(r *http.Request) ([]string, error) {
+	if h.Paperless != nil {
+		types, err := h.Paperless.ListDocumentTypes(r.Context())
 		if err != nil {
-			http.Error(w, fmt.Sprintf("analysis failed on page %d: %s", i+1, err), http.StatusInternalServerError)
-			return
+			return nil, apierror.Upstream(apierror.Paperless, "failed to list document types", err)
 		}
+		names := make([]string, len(types))
+		for i, t := range types {
+			names[i] = t.Name
+		}
+		return names, nil
+	}
+
+	var names []string
+	for _, v := range r.Form["document_types"] {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil, apierror.Invalid("mode=structured requires 'document_types' when Paperless-ngx is not configured")
+	}
+	return names, nil
+}
 
-		resp.Pages = append(resp.Pages, pageResponse{
-			Page:     i + 1,
-			Analysis: analysis,
-		})
+// analyzeStructured returns the work of a structured analysis of every page,
+// merged into one DocumentAnalysis.
+func (h *AnalyzeHandler) analyzeStructured(data []byte, filename string, choices ollama.Choices) jobs.Func {
+	return func(ctx context.Context, job *jobs.Job) (interface{}, error) {
+		ctx = logging.With(ctx, logging.KeyRunID, job.ID, logging.KeyModel, h.Client.Model)
+		pages, err := h.rasterize(data)
+		if err != nil {
+			return nil, apierror.Conversion("failed to convert file", err)
+		}
+		job.SetPages(len(pages))
 
-		log.Printf("Completed %s page %d/%d", header.Filename, i+1, len(images))
+		slog.InfoContext(ctx, "analyzing file", "filename", filename, "pages", len(pages), "mode", api.ModeStructured)
+		analysis, err := pipeline.AnalyzePages(ctx, h.Client, pages, choices, job)
+		countDocument(h.Client.Model, err)
+		if err != nil {
+			return nil, apierror.Upstream(apierror.Ollama, "analysis failed", err)
+		}
+		slog.InfoContext(ctx, "completed file", "filename", filename)
+
+		return api.StructuredResponse{Filename: filename, Pages: len(pages), Analysis: api.DocumentAnalysis(analysis)}, nil
 	}
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+// background returns work to run as a job submitted by the request with the
+// given ID. Its log records carry the request ID, and a failure is logged and
+// reported with it.
+func background(requestID string, work jobs.Func) jobs.Func {
+	return func(ctx context.Context, job *jobs.Job) (interface{}, error) {
+		ctx = logging.WithRequestID(ctx, requestID)
+		result, err := work(ctx, job)
+		if err != nil && ctx.Err() == nil {
+			apierror.Log(ctx, "analysis failed", err)
+			e := *apierror.From(err)
+			e.RequestID = requestID
+			return nil, &e
+		}
+		return result, err
+	}
+}
+
+// submitError classifies a failure to queue a job.
+func submitError(err error) error {
+	switch {
+	case errors.Is(err, jobs.ErrQueueFull):
+		return &apierror.Error{Status: http.StatusServiceUnavailable, Code: api.CodeQueueFull, Message: err.Error(), Retryable: true}
+	case errors.Is(err, jobs.ErrDraining):
+		return &apierror.Error{Status: http.StatusServiceUnavailable, Code: api.CodeShuttingDown, Message: err.Error(), Retryable: true}
+	default:
+		return err
+	}
+}
+
+// rasterize converts an uploaded file to page images, timing the rasterize stage.
+func (h *AnalyzeHandler) rasterize(data []byte) ([]converter.Page, error) {
+	start := time.Now()
+	pages, err := converter.FileToPages(data, h.DebugDir, h.Raster)
+	if err == nil {
+		metrics.ObserveStage(h.Client.Model, metrics.StageRasterize, start)
+	}
+	return pages, err
+}
+
+// countDocument counts an analyzed document as succeeded or failed.
+func countDocument(model string, err error) {
+	status := metrics.DocumentSucceeded
+	if err != nil {
+		status = metrics.DocumentFailed
+	}
+	metrics.DocumentsProcessed.WithLabelValues(model, status).Inc()
 }
@@ -1,9 +1,10 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
 
+	"github.com/bartlettc22/paperless-llm-processor/api"
+	"github.com/bartlettc22/paperless-llm-processor/internal/apierror"
 	"github.com/bartlettc22/paperless-llm-processor/internal/paperless"
 )
 
@@ -13,21 +14,24 @@ type DocumentsHandler struct {
 
 func (h *DocumentsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		apierror.Write(w, r, apierror.MethodNotAllowed)
 		return
 	}
 
 	if h.Client == nil {
-		http.Error(w, "paperless-ngx not configured (set PAPERLESS_URL and PAPERLESS_TOKEN)", http.StatusServiceUnavailable)
+		apierror.Write(w, r, apierror.NotConfigured)
 		return
 	}
 
 	docs, err := h.Client.ListDocuments(r.Context())
 	if err != nil {
-		http.Error(w, "failed to list documents: "+err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.Upstream(apierror.Paperless, "failed to list documents", err))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(docs)
+	out := make([]api.Document, len(docs))
+	for i, doc := range docs {
+		out[i] = api.Document{ID: doc.ID, Title: doc.Title}
+	}
+	writeJSON(w, http.StatusOK, out)
 }
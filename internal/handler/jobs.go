@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/bartlettc22/paperless-llm-processor/api"
+	"github.com/bartlettc22/paperless-llm-processor/internal/apierror"
+	"github.com/bartlettc22/paperless-llm-processor/internal/auth"
+	"github.com/bartlettc22/paperless-llm-processor/internal/jobs"
+	"github.com/bartlettc22/paperless-llm-processor/internal/logging"
+)
+
+// JobsHandler serves the status, result and cancellation of background analyses.
+// Routes must provide the job ID as the {id} path value. Jobs are only visible to
+// the credential that submitted them; others get 404 as for unknown jobs.
+type JobsHandler struct {
+	Queue *jobs.Queue
+}
+
+// Status returns the job's status and per-page progress.
+func (h *JobsHandler) Status(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.job(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, job.Info())
+}
+
+// Result returns the job's result once it has finished: 200 on success, 409 while
+// it is still queued or running, and 422 if it failed or was canceled.
+func (h *JobsHandler) Result(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.job(w, r)
+	if !ok {
+		return
+	}
+	status, result, err := job.Result()
+	resp := api.JobResult{Status: api.JobStatus(status)}
+	if err != nil {
+		resp.Error = apierror.From(err).API(logging.RequestID(r.Context()))
+	}
+	if result != nil {
+		if resp.Result, err = json.Marshal(result); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+	}
+	switch status {
+	case jobs.StatusSucceeded:
+		writeJSON(w, http.StatusOK, resp)
+	case jobs.StatusFailed, jobs.StatusCanceled:
+		writeJSON(w, http.StatusUnprocessableEntity, resp)
+	default:
+		writeJSON(w, http.StatusConflict, resp)
+	}
+}
+
+// Cancel stops a queued or running job.
+func (h *JobsHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.job(w, r); !ok {
+		return
+	}
+	job, err := h.Queue.Cancel(r.PathValue("id"))
+	if errors.Is(err, jobs.ErrNotFound) {
+		apierror.Write(w, r, apierror.New(http.StatusNotFound, api.CodeNotFound, err.Error()))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, job.Info())
+}
+
+// job returns the request's job if it belongs to the request's credential.
+func (h *JobsHandler) job(w http.ResponseWriter, r *http.Request) (*jobs.Job, bool) {
+	job, err := h.Queue.Get(r.PathValue("id"))
+	if err == nil && job.Owner != auth.Name(r.Context()) {
+		err = jobs.ErrNotFound
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.New(http.StatusNotFound, api.CodeNotFound, err.Error()))
+		return nil, false
+	}
+	return job, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/bartlettc22/paperless-llm-processor/api"
+	"github.com/bartlettc22/paperless-llm-processor/internal/apierror"
+	"github.com/bartlettc22/paperless-llm-processor/internal/converter"
+	"github.com/bartlettc22/paperless-llm-processor/internal/logging"
+	"github.com/bartlettc22/paperless-llm-processor/internal/metrics"
+	"github.com/bartlettc22/paperless-llm-processor/internal/ollama"
+	"github.com/bartlettc22/paperless-llm-processor/internal/paperless"
+	"github.com/bartlettc22/paperless-llm-processor/internal/pipeline"
+)
+
+// ProcessHandler runs the batch pipeline for a single Paperless-ngx document.
+// Routes must provide the document ID as the {id} path value.
+type ProcessHandler struct {
+	Paperless *paperless.Client
+	Ollama    *ollama.Client
+	DebugDir  string
+	Raster    converter.Options
+	// Rules choose the storage path, owner and permissions.
+	Rules pipeline.Rules
+	// SummaryNote also writes the summary as a document note.
+	SummaryNote bool
+	// Models are the models a request may choose instead of Ollama.Model.
+	Models []string
+}
+
+func (h *ProcessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Paperless == nil {
+		apierror.Write(w, r, apierror.NotConfigured)
+		return
+	}
+
+	docID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.Invalid("invalid document ID"))
+		return
+	}
+
+	var req api.ProcessRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		apierror.Write(w, r, apierror.Invalid("invalid request body: "+err.Error()))
+		return
+	}
+	fields := make(map[string]bool)
+	for _, f := range req.Fields {
+		if !slices.Contains(pipeline.Fields, f) {
+			apierror.Write(w, r, apierror.Invalid(fmt.Sprintf("unknown field %q (valid: %v)", f, pipeline.Fields)))
+			return
+		}
+		fields[f] = true
+	}
+	if len(fields) == 0 {
+		for _, f := range pipeline.Fields {
+			fields[f] = true
+		}
+	}
+
+	client := h.Ollama
+	if req.Model != "" && req.Model != h.Ollama.Model {
+		if !slices.Contains(h.Models, req.Model) {
+			apierror.Write(w, r, apierror.Invalid(fmt.Sprintf("model %q is not allowed (allowed: %v)", req.Model, append([]string{h.Ollama.Model}, h.Models...))))
+			return
+		}
+		override := *h.Ollama
+		override.Model = req.Model
+		client = &override
+	}
+
+	r = r.WithContext(logging.With(r.Context(), logging.KeyRunID, logging.NewRunID(), logging.KeyDocumentID, docID, logging.KeyModel, client.Model))
+	ctx := r.Context()
+	doc, err := h.Paperless.GetDocument(ctx, docID)
+	var statusErr *paperless.StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		apierror.Write(w, r, apierror.New(http.StatusNotFound, api.CodeNotFound, fmt.Sprintf("document %d not found", docID)))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Upstream(apierror.Paperless, "failed to fetch document", err))
+		return
+	}
+
+	fieldIDs, err := h.customFieldIDs(r, req.DryRun)
+	var mismatch *paperless.DataTypeMismatchError
+	if errors.As(err, &mismatch) {
+		apierror.Write(w, r, apierror.New(http.StatusConflict, api.CodeConflict,
+			fmt.Sprintf("custom field '%s' has data type %s, expected %s", mismatch.Name, mismatch.Got, mismatch.Want)))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, apierror.Upstream(apierror.Paperless, "failed to prepare custom fields", err))
+		return
+	}
+	catalog, err := pipeline.LoadCatalog(ctx, h.Paperless)
+	if err != nil {
+		apierror.Write(w, r, apierror.Upstream(apierror.Paperless, "failed to load correspondents, document types and tags", err))
+		return
+	}
+
+	start := time.Now()
+	data, err := h.Paperless.DownloadDocument(ctx, docID)
+	if err != nil {
+		countDocument(client.Model, err)
+		apierror.Write(w, r, apierror.Upstream(apierror.Paperless, "failed to download document", err))
+		return
+	}
+	metrics.ObserveStage(client.Model, metrics.StageDownload, start)
+
+	start = time.Now()
+	pages, err := converter.FileToPages(data, h.DebugDir, h.Raster)
+	if err != nil {
+		countDocument(client.Model, err)
+		apierror.Write(w, r, apierror.Conversion("failed to convert document", err))
+		return
+	}
+	metrics.ObserveStage(client.Model, metrics.StageRasterize, start)
+
+	slog.InfoContext(ctx, "processing document", "pages", len(pages), "dry_run", req.DryRun)
+	merged, err := pipeline.AnalyzePages(ctx, client, pages, h.Rules.Choices(catalog), nil)
+	if err != nil {
+		countDocument(client.Model, err)
+		apierror.Write(w, r, apierror.Upstream(apierror.Ollama, "analysis failed", err))
+		return
+	}
+
+	changes := pipeline.Plan(ctx, merged, fields, catalog, h.Rules)
+	before := pipeline.StateOf(doc, catalog, fieldIDs[pipeline.SummaryField])
+	resp := api.ProcessResponse{
+		DocumentID: docID,
+		DryRun:     req.DryRun,
+		Model:      client.Model,
+		Analysis:   api.DocumentAnalysis(merged),
+		Changes:    apiChanges(pipeline.Diff(before, before.Apply(changes))),
+		Skipped:    apiSkipped(changes.Skipped),
+	}
+
+	if !req.DryRun {
+		start = time.Now()
+		proc := &pipeline.Processor{
+			Paperless:      h.Paperless,
+			Catalog:        catalog,
+			SummaryFieldID: fieldIDs[pipeline.SummaryField],
+			Model:          client.Model,
+			SummaryNote:    h.SummaryNote,
+		}
+		update, skipped := proc.BuildUpdate(ctx, changes)
+		resp.Changes = apiChanges(pipeline.Diff(before, before.ApplyUpdate(update, catalog, fieldIDs[pipeline.SummaryField])))
+		resp.Skipped = apiSkipped(skipped)
+		update.CustomFields = paperless.MergeCustomFields(doc.CustomFields, append(update.CustomFields,
+			paperless.CustomFieldValue{Field: fieldIDs[pipeline.ProcessField], Value: pipeline.ProcessID},
+			paperless.CustomFieldValue{Field: fieldIDs[pipeline.ModelField], Value: client.Model},
+		))
+		if err := proc.Update(ctx, docID, update, merged.Summary); err != nil {
+			countDocument(client.Model, err)
+			apierror.Write(w, r, apierror.Upstream(apierror.Paperless, "failed to update document", err))
+			return
+		}
+		metrics.ObserveStage(client.Model, metrics.StageUpdate, start)
+		resp.Updated = true
+		slog.InfoContext(ctx, "updated document", "changed_fields", len(resp.Changes))
+	}
+
+	countDocument(client.Model, nil)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// customFieldIDs returns the IDs of the processor's custom fields by name. Unless
+// dryRun is set, missing fields are created; in a dry run they are reported as 0.
+func (h *ProcessHandler) customFieldIDs(r *http.Request, dryRun bool) (map[string]int, error) {
+	specs := []paperless.CustomFieldSpec{
+		{Name: pipeline.ProcessField, DataType: paperless.DataTypeInteger},
+		{Name: pipeline.SummaryField, DataType: paperless.DataTypeLongText},
+		{Name: pipeline.ModelField, DataType: paperless.DataTypeString},
+	}
+	ids := make(map[string]int, len(specs))
+
+	if dryRun {
+		fields, err := h.Paperless.ListCustomFields(r.Context())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list custom fields: %w", err)
+		}
+		for _, f := range fields {
+			ids[f.Name] = f.ID
+		}
+		return ids, nil
+	}
+
+	for _, spec := range specs {
+		f, err := h.Paperless.EnsureCustomFieldSpec(r.Context(), spec, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to ensure custom field '%s': %w", spec.Name, err)
+		}
+		ids[spec.Name] = f.ID
+	}
+	return ids, nil
+}
+
+func apiChanges(changes []pipeline.FieldChange) []api.FieldChange {
+	out := make([]api.FieldChange, len(changes))
+	for i, c := range changes {
+		out[i] = api.FieldChange(c)
+	}
+	return out
+}
+
+func apiSkipped(skipped []pipeline.SkippedField) []api.SkippedField {
+	var out []api.SkippedField
+	for _, s := range skipped {
+		out = append(out, api.SkippedField(s))
+	}
+	return out
+}
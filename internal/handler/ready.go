@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bartlettc22/paperless-llm-processor/api"
+	"github.com/bartlettc22/paperless-llm-processor/internal/apierror"
+	"github.com/bartlettc22/paperless-llm-processor/internal/jobs"
+	"github.com/bartlettc22/paperless-llm-processor/internal/ollama"
+	"github.com/bartlettc22/paperless-llm-processor/internal/paperless"
+)
+
+// ReadyHandler reports whether the server can take work: Ollama is reachable
+// with the model available, Paperless-ngx (when configured) responds, and the
+// server is not shutting down.
+type ReadyHandler struct {
+	Ollama    *ollama.Client
+	Paperless *paperless.Client
+	Jobs      *jobs.Queue
+	// Timeout bounds each dependency check (default 5s).
+	Timeout time.Duration
+}
+
+func (h *ReadyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	var (
+		wg          sync.WaitGroup
+		ollamaCheck api.CheckResult
+		paperCheck  = api.CheckResult{Status: api.CheckNotConfigured}
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ollamaCheck = check(ctx, apierror.Ollama, h.Ollama.CheckModel)
+		ollamaCheck.Model = h.Ollama.Model
+	}()
+	if h.Paperless != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			paperCheck = check(ctx, apierror.Paperless, h.Paperless.Ping)
+		}()
+	}
+	wg.Wait()
+
+	resp := api.ReadyResponse{
+		ShuttingDown: h.Jobs != nil && h.Jobs.Draining(),
+		Dependencies: map[string]api.CheckResult{"ollama": ollamaCheck, "paperless": paperCheck},
+	}
+	resp.Ready = !resp.ShuttingDown && ollamaCheck.Status == api.CheckOK && paperCheck.Status != api.CheckFailed
+
+	status := http.StatusOK
+	if !resp.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
+
+// check runs one dependency check and times it. /ready is unauthenticated, so a
+// failure is reported by its classified reason and the cause is only logged.
+func check(ctx context.Context, service string, fn func(context.Context) error) api.CheckResult {
+	start := time.Now()
+	err := fn(ctx)
+	res := api.CheckResult{Status: api.CheckOK, LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		res.Status = api.CheckFailed
+		res.Error = apierror.Upstream(service, "", err).Message
+		slog.WarnContext(ctx, "readiness check failed", "service", service, "error", err)
+	}
+	return res
+}
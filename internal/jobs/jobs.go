@@ -0,0 +1,380 @@
+// Package jobs runs long analyses on a bounded in-process queue and keeps their
+// progress and results for polling.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Status is the lifecycle state of a job.
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCanceled  Status = "canceled"
+)
+
+// Done reports whether the status is final.
+func (s Status) Done() bool {
+	return s == StatusSucceeded || s == StatusFailed || s == StatusCanceled
+}
+
+var (
+	// ErrQueueFull is returned by Submit when the queue has no free slot.
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrNotFound is returned for unknown or expired job IDs.
+	ErrNotFound = errors.New("job not found")
+	// ErrDraining is returned by Submit once Drain has been called.
+	ErrDraining = errors.New("job queue is shutting down")
+)
+
+// Func does a job's work. It should return promptly once ctx is canceled and
+// report page progress through the job.
+type Func func(ctx context.Context, job *Job) (interface{}, error)
+
+// Page statuses reported in Info.Pages.
+const (
+	PagePending = "pending"
+	PageRunning = "running"
+	PageDone    = "done"
+	PageFailed  = "failed"
+)
+
+// PageProgress is the progress of one page of a job.
+type PageProgress struct {
+	Page   int    `json:"page"`
+	Status string `json:"status"`
+	// DurationMS is the time spent on the page once it has finished.
+	DurationMS int64 `json:"duration_ms,omitempty"`
+
+	started time.Time
+}
+
+// Job is a unit of work tracked by a Queue.
+type Job struct {
+	ID string
+	// Owner identifies who submitted the job, e.g. the name of their credential.
+	Owner string
+
+	mu       sync.Mutex
+	status   Status
+	created  time.Time
+	started  time.Time
+	finished time.Time
+	pages    []PageProgress
+	result   interface{}
+	err      error
+	fn       Func
+	cancel   context.CancelFunc
+}
+
+// Info is a point-in-time view of a job.
+type Info struct {
+	ID         string         `json:"id"`
+	Status     Status         `json:"status"`
+	Created    time.Time      `json:"created"`
+	Started    *time.Time     `json:"started,omitempty"`
+	Finished   *time.Time     `json:"finished,omitempty"`
+	PagesTotal int            `json:"pages_total"`
+	PagesDone  int            `json:"pages_done"`
+	Pages      []PageProgress `json:"pages"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// NewJob returns a job that is not tracked by any queue, for running fn directly.
+func NewJob() *Job {
+	return &Job{ID: newID(), status: StatusQueued, created: time.Now()}
+}
+
+// SetPages declares the number of pages the job will work through.
+func (j *Job) SetPages(n int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.pages = make([]PageProgress, n)
+	for i := range j.pages {
+		j.pages[i] = PageProgress{Page: i + 1, Status: PagePending}
+	}
+}
+
+// StartPage marks page i (0-based) as running.
+func (j *Job) StartPage(i int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if i < len(j.pages) {
+		j.pages[i].Status = PageRunning
+		j.pages[i].started = time.Now()
+	}
+}
+
+// FinishPage marks page i (0-based) as done, or failed if err is non-nil.
+func (j *Job) FinishPage(i int, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if i >= len(j.pages) {
+		return
+	}
+	j.pages[i].Status = PageDone
+	if err != nil {
+		j.pages[i].Status = PageFailed
+	}
+	j.pages[i].DurationMS = time.Since(j.pages[i].started).Milliseconds()
+}
+
+// Info returns the job's current state.
+func (j *Job) Info() Info {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	info := Info{
+		ID:         j.ID,
+		Status:     j.status,
+		Created:    j.created,
+		PagesTotal: len(j.pages),
+		Pages:      append([]PageProgress{}, j.pages...),
+	}
+	if !j.started.IsZero() {
+		started := j.started
+		info.Started = &started
+	}
+	if !j.finished.IsZero() {
+		finished := j.finished
+		info.Finished = &finished
+	}
+	for _, p := range j.pages {
+		if p.Status == PageDone {
+			info.PagesDone++
+		}
+	}
+	if j.err != nil {
+		info.Error = j.err.Error()
+	}
+	return info
+}
+
+// Result returns the job's status, result and error.
+func (j *Job) Result() (Status, interface{}, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status, j.result, j.err
+}
+
+// finish records the outcome unless the job already ended.
+func (j *Job) finish(status Status, result interface{}, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.status.Done() {
+		return
+	}
+	j.status, j.result, j.err = status, result, err
+	j.finished = time.Now()
+	j.fn = nil
+}
+
+// Queue runs submitted jobs on a fixed number of workers. At most size jobs wait
+// for a worker; finished jobs are kept for ttl.
+type Queue struct {
+	ttl     time.Duration
+	pending chan *Job
+	ctx     context.Context
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+	// active counts submitted jobs that have not been run or skipped yet.
+	active sync.WaitGroup
+
+	mu       sync.Mutex
+	jobs     map[string]*Job
+	draining bool
+}
+
+// NewQueue starts workers goroutines taking jobs from a queue of the given size.
+func NewQueue(workers, size int, ttl time.Duration) *Queue {
+	ctx, stop := context.WithCancel(context.Background())
+	q := &Queue{
+		ttl:     ttl,
+		pending: make(chan *Job, size),
+		ctx:     ctx,
+		stop:    stop,
+		jobs:    make(map[string]*Job),
+	}
+	for range workers {
+		q.wg.Add(1)
+		go q.work()
+	}
+	go q.expire()
+	return q
+}
+
+// Submit queues fn on behalf of owner and returns its job, or ErrQueueFull.
+func (q *Queue) Submit(owner string, fn Func) (*Job, error) {
+	job := NewJob()
+	job.Owner = owner
+	job.fn = fn
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.draining {
+		return nil, ErrDraining
+	}
+	q.active.Add(1)
+	select {
+	case q.pending <- job:
+	default:
+		q.active.Done()
+		return nil, ErrQueueFull
+	}
+	q.jobs[job.ID] = job
+	return job, nil
+}
+
+// Get returns the job with the given ID.
+func (q *Queue) Get(id string) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return job, nil
+}
+
+// Cancel stops a queued or running job. Canceling a finished job has no effect.
+func (q *Queue) Cancel(id string) (*Job, error) {
+	job, err := q.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	job.mu.Lock()
+	cancel := job.cancel
+	job.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	job.finish(StatusCanceled, nil, context.Canceled)
+	return job, nil
+}
+
+// Len returns the number of jobs waiting for a worker.
+func (q *Queue) Len() int {
+	return len(q.pending)
+}
+
+// Close cancels running jobs and stops the workers.
+func (q *Queue) Close() {
+	q.stop()
+	q.wg.Wait()
+}
+
+// Drain stops accepting jobs and waits for queued and running jobs to finish,
+// then stops the workers. If ctx ends first, the remaining jobs are canceled
+// and ctx's error is returned.
+func (q *Queue) Drain(ctx context.Context) error {
+	q.mu.Lock()
+	q.draining = true
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		q.Close()
+		return nil
+	case <-ctx.Done():
+	}
+
+	q.Close()
+	for {
+		select {
+		case job := <-q.pending:
+			job.finish(StatusCanceled, nil, context.Canceled)
+			q.active.Done()
+		default:
+			return ctx.Err()
+		}
+	}
+}
+
+// Draining reports whether Drain has been called.
+func (q *Queue) Draining() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.draining
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case job := <-q.pending:
+			q.run(job)
+		}
+	}
+}
+
+func (q *Queue) run(job *Job) {
+	defer q.active.Done()
+	ctx, cancel := context.WithCancel(q.ctx)
+	defer cancel()
+
+	job.mu.Lock()
+	if job.status.Done() { // canceled while queued
+		job.mu.Unlock()
+		return
+	}
+	fn := job.fn
+	job.status = StatusRunning
+	job.started = time.Now()
+	job.cancel = cancel
+	job.mu.Unlock()
+
+	result, err := fn(ctx, job)
+	switch {
+	case ctx.Err() != nil:
+		job.finish(StatusCanceled, nil, ctx.Err())
+	case err != nil:
+		job.finish(StatusFailed, nil, err)
+	default:
+		job.finish(StatusSucceeded, result, nil)
+	}
+}
+
+// expire drops finished jobs older than the TTL.
+func (q *Queue) expire() {
+	interval := min(max(q.ttl/10, time.Second), time.Minute)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case now := <-ticker.C:
+			q.mu.Lock()
+			for id, job := range q.jobs {
+				job.mu.Lock()
+				expired := job.status.Done() && now.Sub(job.finished) > q.ttl
+				job.mu.Unlock()
+				if expired {
+					delete(q.jobs, id)
+				}
+			}
+			q.mu.Unlock()
+		}
+	}
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
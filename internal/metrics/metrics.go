@@ -0,0 +1,121 @@
+// Package metrics defines the Prometheus metrics exported by the server and the
+// batch processor. Every metric is labeled with the Ollama model.
+package metrics
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "paperless_llm"
+
+// Pipeline stages timed by StageDuration.
+const (
+	StageDownload  = "download"
+	StageRasterize = "rasterize"
+	StageLLM       = "llm"
+	StageUpdate    = "update"
+)
+
+// Document outcomes counted by DocumentsProcessed.
+const (
+	DocumentSucceeded = "succeeded"
+	DocumentFailed    = "failed"
+)
+
+// Registry holds all metrics, plus the Go runtime and process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// PagesProcessed counts pages analyzed successfully.
+	PagesProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pages_processed_total",
+		Help:      "Pages analyzed successfully.",
+	}, []string{"model"})
+
+	// DocumentsProcessed counts documents by outcome.
+	DocumentsProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "documents_processed_total",
+		Help:      "Documents processed, by outcome (succeeded or failed).",
+	}, []string{"model", "status"})
+
+	// StageDuration times each pipeline stage.
+	StageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "stage_duration_seconds",
+		Help:      "Duration of pipeline stages (download, rasterize, llm, update).",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"model", "stage"})
+
+	// OllamaErrors counts failed Ollama requests by error class.
+	OllamaErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ollama_errors_total",
+		Help:      "Failed Ollama requests, by error class.",
+	}, []string{"model", "class"})
+
+	// TruncatedResponses counts Ollama responses returned with done=false.
+	TruncatedResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ollama_truncated_responses_total",
+		Help:      "Ollama responses that were incomplete (done=false).",
+	}, []string{"model"})
+
+	// Created counts correspondents and tags created in Paperless-ngx.
+	Created = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "paperless_created_total",
+		Help:      "Correspondents and tags created in Paperless-ngx, by kind.",
+	}, []string{"model", "kind"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		PagesProcessed,
+		DocumentsProcessed,
+		StageDuration,
+		OllamaErrors,
+		TruncatedResponses,
+		Created,
+	)
+}
+
+// ObserveStage records the time since start for a pipeline stage.
+func ObserveStage(model, stage string, start time.Time) {
+	StageDuration.WithLabelValues(model, stage).Observe(time.Since(start).Seconds())
+}
+
+// RegisterQueueDepth exports the number of queued analyses, as reported by depth.
+func RegisterQueueDepth(model string, depth func() int) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Name:        "queue_depth",
+		Help:        "Analyses waiting for a worker.",
+		ConstLabels: prometheus.Labels{"model": model},
+	}, func() float64 { return float64(depth()) }))
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Serve exposes /metrics on addr in the background.
+func Serve(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			slog.Error("metrics listener failed", "addr", addr, "error", err)
+		}
+	}()
+}
@@ -0,0 +1,112 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bartlettc22/paperless-llm-processor/internal/metrics"
+)
+
+// Error classes counted in metrics.OllamaErrors.
+const (
+	ErrClassTimeout    = "timeout"
+	ErrClassCanceled   = "canceled"
+	ErrClassConnection = "connection"
+	ErrClassStatus     = "status"
+	ErrClassDecode     = "decode"
+	ErrClassModel      = "model"
+	ErrClassEmpty      = "empty"
+	ErrClassParse      = "parse"
+)
+
+// StatusError is an unexpected HTTP status from Ollama.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("ollama returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// chat sends a request to the chat endpoint and decodes the response, also
+// returning the raw response body. It records the request latency, failures by
+// error class and incomplete (done=false) responses in the metrics.
+func (c *Client) chat(ctx context.Context, reqBody chatRequest) (*chatResponse, []byte, error) {
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	start := time.Now()
+	defer metrics.ObserveStage(c.Model, metrics.StageLLM, start)
+
+	resp, err := c.postChat(ctx, body)
+	if err != nil {
+		c.countError(requestErrorClass(ctx, err))
+		return nil, nil, fmt.Errorf("calling ollama API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.countError(requestErrorClass(ctx, err))
+		return nil, nil, fmt.Errorf("reading response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		c.countError(ErrClassStatus)
+		return nil, respBody, &StatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	var result chatResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		c.countError(ErrClassDecode)
+		return nil, respBody, fmt.Errorf("decoding response: %w: body=%s", err, string(respBody))
+	}
+
+	if result.Error != "" {
+		c.countError(ErrClassModel)
+		return nil, respBody, fmt.Errorf("ollama error: %s", result.Error)
+	}
+
+	if !result.Done {
+		metrics.TruncatedResponses.WithLabelValues(c.Model).Inc()
+	}
+
+	return &result, respBody, nil
+}
+
+// postChat sends a request body to the chat endpoint.
+func (c *Client) postChat(ctx context.Context, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.HTTP.Do(req)
+}
+
+func (c *Client) countError(class string) {
+	metrics.OllamaErrors.WithLabelValues(c.Model, class).Inc()
+}
+
+// requestErrorClass classifies a failure to send a request or read its response.
+func requestErrorClass(ctx context.Context, err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		return ErrClassTimeout
+	case errors.Is(err, context.Canceled) || ctx.Err() != nil:
+		return ErrClassCanceled
+	default:
+		return ErrClassConnection
+	}
+}
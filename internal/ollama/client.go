@@ -1,11 +1,12 @@
 package ollama
 
 import (
-	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
@@ -47,6 +48,15 @@ type DocumentAnalysis struct {
 	DocumentDate  string   `json:"document_date"`
 	Correspondent string   `json:"correspondent"`
 	Tags          []string `json:"tags"`
+	StoragePath   string   `json:"storage_path,omitempty"`
+}
+
+// Choices holds the names the model must choose from in a structured analysis.
+type Choices struct {
+	// DocumentTypes are the valid document type names.
+	DocumentTypes []string
+	// StoragePaths are the valid storage path names. When empty, no storage path is requested.
+	StoragePaths []string
 }
 
 type chatResponse struct {
@@ -70,7 +80,7 @@ func NewClient(baseURL, model string) *Client {
 }
 
 // Analyze sends base64-encoded images to the Ollama vision model with the given prompt.
-func (c *Client) Analyze(prompt string, imagesBase64 []string) (string, error) {
+func (c *Client) Analyze(ctx context.Context, prompt string, imagesBase64 []string) (string, error) {
 	reqBody := chatRequest{
 		Model: c.Model,
 		Messages: []chatMessage{
@@ -79,75 +89,76 @@ func (c *Client) Analyze(prompt string, imagesBase64 []string) (string, error) {
 		Stream: false,
 	}
 
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("marshaling request: %w", err)
-	}
-
-	resp, err := c.HTTP.Post(c.BaseURL+"/api/chat", "application/json", bytes.NewReader(body))
+	result, _, err := c.chat(ctx, reqBody)
 	if err != nil {
-		return "", fmt.Errorf("calling ollama API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, string(respBody))
-	}
-
-	var result chatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("decoding response: %w", err)
+		return "", err
 	}
 
 	return result.Message.Content, nil
 }
 
-func buildSchema(documentTypes []string) json.RawMessage {
-	schema := map[string]interface{}{
-		"type": "object",
-		"properties": map[string]interface{}{
-			"file_name": map[string]interface{}{
-				"type":        "string",
-				"description": "Suggested file name for the document",
-			},
-			"document_type": map[string]interface{}{
-				"type":        "string",
-				"enum":        documentTypes,
-				"description": "The type of document",
-			},
-			"document_date": map[string]interface{}{
-				"type":        "string",
-				"description": "The date of the document in YYYY-MM-DD format, or empty string if not confidently determined",
-			},
-			"summary": map[string]interface{}{
-				"type":        "string",
-				"description": "A concise summary of the document including what it is, relevant dates, people, transactions, entities, accounts, and key details.",
-			},
-			"transcription": map[string]interface{}{
-				"type":        "string",
-				"description": "A full transcription of all visible text on this page, preserving the original wording and layout as much as possible.",
-			},
-			"correspondent": map[string]interface{}{
-				"type":        "string",
-				"description": "The primary correspondent: the person, business, organization, or entity that sent or is the main subject of this document. Use proper name and title case. Empty string if none.",
-			},
-			"tags": map[string]interface{}{
-				"type":        "array",
-				"description": "ONLY proper names of specific people, companies, or organizations (e.g. 'John Smith', 'Acme Corp', 'IRS'). NEVER include generic terms, descriptions, diagnoses, topics, or categories. If no proper names apply, return an empty array.",
-				"items": map[string]interface{}{
-					"type": "string",
-				},
+func buildSchema(choices Choices) json.RawMessage {
+	properties := map[string]interface{}{
+		"file_name": map[string]interface{}{
+			"type":        "string",
+			"description": "Suggested file name for the document",
+		},
+		"document_type": map[string]interface{}{
+			"type":        "string",
+			"enum":        choices.DocumentTypes,
+			"description": "The type of document",
+		},
+		"document_date": map[string]interface{}{
+			"type":        "string",
+			"description": "The date of the document in YYYY-MM-DD format, or empty string if not confidently determined",
+		},
+		"summary": map[string]interface{}{
+			"type":        "string",
+			"description": "A concise summary of the document including what it is, relevant dates, people, transactions, entities, accounts, and key details.",
+		},
+		"transcription": map[string]interface{}{
+			"type":        "string",
+			"description": "A full transcription of all visible text on this page, preserving the original wording and layout as much as possible.",
+		},
+		"correspondent": map[string]interface{}{
+			"type":        "string",
+			"description": "The primary correspondent: the person, business, organization, or entity that sent or is the main subject of this document. Use proper name and title case. Empty string if none.",
+		},
+		"tags": map[string]interface{}{
+			"type":        "array",
+			"description": "ONLY proper names of specific people, companies, or organizations (e.g. 'John Smith', 'Acme Corp', 'IRS'). NEVER include generic terms, descriptions, diagnoses, topics, or categories. If no proper names apply, return an empty array.",
+			"items": map[string]interface{}{
+				"type": "string",
 			},
 		},
-		"required": []string{"summary", "transcription", "file_name", "document_type", "document_date", "correspondent", "tags"},
+	}
+	required := []string{"summary", "transcription", "file_name", "document_type", "document_date", "correspondent", "tags"}
+	if len(choices.StoragePaths) > 0 {
+		properties["storage_path"] = map[string]interface{}{
+			"type":        "string",
+			"enum":        choices.StoragePaths,
+			"description": "The storage path (folder) the document should be filed under",
+		}
+		required = append(required, "storage_path")
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
 	}
 	data, _ := json.Marshal(schema)
 	return data
 }
 
-func buildPrompt(documentTypes []string) string {
-	typeList := strings.Join(documentTypes, ", ")
+func buildPrompt(choices Choices) string {
+	typeList := strings.Join(choices.DocumentTypes, ", ")
+	fields := `"summary", "transcription", "file_name", "document_type", "document_date", "correspondent", and "tags"`
+	storagePath := ""
+	if len(choices.StoragePaths) > 0 {
+		storagePath = fmt.Sprintf("\n8. The storage path (folder) the document should be filed under, which must be one of: %s.", strings.Join(choices.StoragePaths, ", "))
+		fields = `"summary", "transcription", "file_name", "document_type", "document_date", "correspondent", "tags", and "storage_path"`
+	}
 	return fmt.Sprintf(`You are looking at a single page of a document. Analyze this page image and provide:
 1. A concise summary of this page's content: what it is, relevant dates, people, transactions, entities, accounts, and any other key details.
 2. A full transcription of all visible text on this page. Preserve the meaningful content and general structure, but normalize whitespace - use single spaces between words and single newlines between lines or sections. Do NOT repeat tabs, newlines, or spaces excessively. For barcodes, tracking numbers, or long sequences of repeated characters, just note their presence (e.g. "[barcode]") rather than transcribing every digit.
@@ -155,22 +166,32 @@ func buildPrompt(documentTypes []string) string {
 4. The document type, which must be one of: %s.
 5. The document date in YYYY-MM-DD format. Only provide a date if you are confident it is the primary date of the document (e.g. invoice date, letter date, transaction date). Use an empty string if uncertain.
 6. The correspondent: the primary person, business, organization, or entity that sent or is the main subject of this document. Use proper name and title case. Use an empty string if none.
-7. Tags: ONLY proper names of specific people, companies, or organizations mentioned in the document (e.g. "John Smith", "Acme Corp", "IRS"). NEVER include generic terms, descriptions, diagnoses, topics, or categories (e.g. do NOT include things like "Left lower quadrant pain", "Invoice", "Medical Records"). If no proper names apply, return an empty array.
+7. Tags: ONLY proper names of specific people, companies, or organizations mentioned in the document (e.g. "John Smith", "Acme Corp", "IRS"). NEVER include generic terms, descriptions, diagnoses, topics, or categories (e.g. do NOT include things like "Left lower quadrant pain", "Invoice", "Medical Records"). If no proper names apply, return an empty array.%s
 
-Respond with JSON containing "summary", "transcription", "file_name", "document_type", "document_date", "correspondent", and "tags" fields.  The response MUST be valid JSON.`, typeList)
+Respond with JSON containing %s fields.  The response MUST be valid JSON.`, typeList, storagePath, fields)
+}
+
+// PromptVersion returns a short fingerprint of the structured analysis prompt and
+// schema. It changes whenever either is edited, so results can be traced back to
+// the prompt that produced them.
+func PromptVersion() string {
+	h := sha256.New()
+	h.Write([]byte(buildPrompt(Choices{})))
+	h.Write(buildSchema(Choices{}))
+	return hex.EncodeToString(h.Sum(nil))[:12]
 }
 
 // AnalyzeStructured sends a single page image to the Ollama vision model and returns structured analysis.
-// documentTypes is the list of valid document type names from Paperless-ngx.
-func (c *Client) AnalyzeStructured(imageBase64 string, documentTypes []string) (*DocumentAnalysis, error) {
+// choices holds the valid document type (and optionally storage path) names from Paperless-ngx.
+func (c *Client) AnalyzeStructured(ctx context.Context, imageBase64 string, choices Choices) (*DocumentAnalysis, error) {
 	reqBody := chatRequest{
 		Model: c.Model,
 		Messages: []chatMessage{
-			{Role: "user", Content: buildPrompt(documentTypes), Images: []string{imageBase64}},
+			{Role: "user", Content: buildPrompt(choices), Images: []string{imageBase64}},
 		},
-		Stream:  false,
-		Think:   false,
-		Format:  buildSchema(documentTypes),
+		Stream: false,
+		Think:  false,
+		Format: buildSchema(choices),
 		Options: &modelOptions{
 			Temperature:   0,
 			NumCtx:        65536, // Use more of the 128k context
@@ -179,53 +200,31 @@ func (c *Client) AnalyzeStructured(imageBase64 string, documentTypes []string) (
 		},
 	}
 
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("marshaling request: %w", err)
-	}
+	slog.DebugContext(ctx, "sending request to Ollama",
+		"num_ctx", reqBody.Options.NumCtx, "num_predict", reqBody.Options.NumPredict)
 
-	log.Printf("  Sending request to Ollama (model=%s, num_ctx=%d, num_predict=%d)...",
-		c.Model, reqBody.Options.NumCtx, reqBody.Options.NumPredict)
-
-	resp, err := c.HTTP.Post(c.BaseURL+"/api/chat", "application/json", bytes.NewReader(body))
+	result, respBody, err := c.chat(ctx, reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("calling ollama API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("reading response body: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, string(respBody))
-	}
-
-	var result chatResponse
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return nil, fmt.Errorf("decoding response: %w: body=%s", err, string(respBody))
-	}
-
-	if result.Error != "" {
-		return nil, fmt.Errorf("ollama error: %s", result.Error)
+		return nil, err
 	}
 
 	content := result.Message.Content
-	log.Printf("  Ollama response: done=%v, done_reason=%q, content_len=%d", result.Done, result.DoneReason, len(content))
+	slog.DebugContext(ctx, "Ollama response", "done", result.Done, "done_reason", result.DoneReason, "content_len", len(content))
 
 	if !result.Done {
-		log.Printf("  WARNING: Ollama returned incomplete response (done=false, reason=%q)", result.DoneReason)
+		slog.WarnContext(ctx, "Ollama returned incomplete response", "done_reason", result.DoneReason)
 	}
 
 	if content == "" {
+		c.countError(ErrClassEmpty)
 		return nil, fmt.Errorf("ollama returned empty response: full_body=%s", string(respBody))
 	}
 
-	log.Printf("  Response (first_200=%s ... last_100=%s)", truncateHead(content, 200), truncateTail(content, 100))
+	slog.DebugContext(ctx, "Ollama response content", "first_200", truncateHead(content, 200), "last_100", truncateTail(content, 100))
 
 	var analysis DocumentAnalysis
 	if err := json.Unmarshal([]byte(content), &analysis); err != nil {
+		c.countError(ErrClassParse)
 		return nil, fmt.Errorf("parsing response: %w: len=%d, done=%v, last_200=%s", err, len(content), result.Done, truncateTail(content, 200))
 	}
 
@@ -0,0 +1,44 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrModelNotFound is returned by CheckModel when the model has not been pulled.
+var ErrModelNotFound = errors.New("model not found")
+
+// CheckModel verifies that Ollama is reachable and the client's model is
+// available, using /api/show.
+func (c *Client) CheckModel(ctx context.Context) error {
+	body, err := json.Marshal(map[string]string{"model": c.Model})
+	if err != nil {
+		return fmt.Errorf("marshaling request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/api/show", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling ollama API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrModelNotFound, c.Model)
+	default:
+		respBody, _ := io.ReadAll(resp.Body)
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+}
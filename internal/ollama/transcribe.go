@@ -0,0 +1,193 @@
+package ollama
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+const transcribePrompt = `You are looking at a cropped section of a document page. Transcribe all visible text in this image exactly as written, top to bottom. Preserve line breaks between lines, but normalize whitespace - use single spaces between words. Text cut off at the edges of the image should be transcribed as far as it is legible. For barcodes or long sequences of repeated characters, just note their presence (e.g. "[barcode]"). Respond with the transcription only, without commentary.`
+
+// Transcribe sends a single image to the Ollama vision model and returns a plain-text transcription.
+func (c *Client) Transcribe(ctx context.Context, imageBase64 string) (string, error) {
+	reqBody := chatRequest{
+		Model: c.Model,
+		Messages: []chatMessage{
+			{Role: "user", Content: transcribePrompt, Images: []string{imageBase64}},
+		},
+		Stream: false,
+		Think:  false,
+		Options: &modelOptions{
+			Temperature:   0,
+			NumCtx:        32768,
+			NumPredict:    8192,
+			RepeatPenalty: 1.5,
+		},
+	}
+
+	result, _, err := c.chat(ctx, reqBody)
+	if err != nil {
+		return "", err
+	}
+
+	if !result.Done {
+		slog.WarnContext(ctx, "Ollama returned incomplete transcription", "done_reason", result.DoneReason)
+	}
+
+	return strings.TrimSpace(result.Message.Content), nil
+}
+
+// TranscribeTiles transcribes each tile of a cols x rows grid (tiles in row-major order)
+// and stitches the results into a single page transcription.
+func (c *Client) TranscribeTiles(ctx context.Context, tiles []string, cols, rows int) (string, error) {
+	if len(tiles) != cols*rows {
+		return "", fmt.Errorf("got %d tiles for a %dx%d grid", len(tiles), cols, rows)
+	}
+
+	texts := make([]string, len(tiles))
+	for i, tile := range tiles {
+		slog.DebugContext(ctx, "transcribing tile", "tile", i+1, "tiles", len(tiles), "row", i/cols+1, "col", i%cols+1)
+		text, err := c.Transcribe(ctx, tile)
+		if err != nil {
+			return "", fmt.Errorf("transcribing tile %d: %w", i+1, err)
+		}
+		texts[i] = text
+	}
+
+	return StitchTiles(texts, cols, rows), nil
+}
+
+// StitchTiles joins tile transcriptions (row-major order) into one text. The
+// tiles of each row show the same lines of text, so they are merged line by line
+// from left to right, dropping text repeated in the horizontal overlap. The rows
+// are then joined top to bottom, dropping lines repeated in the vertical overlap.
+func StitchTiles(texts []string, cols, rows int) string {
+	var lines []string
+	for r := 0; r < rows; r++ {
+		var row []string
+		for c := 0; c < cols; c++ {
+			row = mergeColumns(row, splitLines(texts[r*cols+c]))
+		}
+		lines = appendWithoutOverlap(lines, row)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// mergeColumns joins the lines of a tile onto the lines of the tile to its left.
+// Tiles of the same row can hold different numbers of lines, e.g. when a heading
+// sits entirely in one of them, so lines are aligned by the text they share in
+// the horizontal overlap: the pairing with the most overlapping text wins, and
+// lines without a partner are kept as they are, in order. Lines whose overlap
+// was not transcribed identically by both tiles can only be paired by position,
+// so tiles whose line counts also differ may still be merged out of line.
+func mergeColumns(left, right []string) []string {
+	n, m := len(left), len(right)
+	overlap := make([][]int, n)
+	for i := range overlap {
+		overlap[i] = make([]int, m)
+		for j := range overlap[i] {
+			overlap[i][j] = lineOverlap(left[i], right[j])
+		}
+	}
+
+	// best[i][j] scores the alignment of left[i:] and right[j:]. Overlapping
+	// text outweighs any number of pairs without it, and among alignments with
+	// the same overlap the one pairing the most lines wins.
+	weight := n + m + 1
+	pairScore := func(i, j int) int { return overlap[i][j]*weight + 1 }
+	best := make([][]int, n+1)
+	for i := range best {
+		best[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			best[i][j] = max(best[i+1][j+1]+pairScore(i, j), best[i+1][j], best[i][j+1])
+		}
+	}
+
+	merged := make([]string, 0, max(n, m))
+	i, j := 0, 0
+	for i < n && j < m {
+		switch best[i][j] {
+		case best[i+1][j+1] + pairScore(i, j):
+			merged = append(merged, joinWithoutOverlap(left[i], right[j]))
+			i++
+			j++
+		case best[i+1][j]:
+			merged = append(merged, left[i])
+			i++
+		default:
+			merged = append(merged, right[j])
+			j++
+		}
+	}
+	merged = append(merged, left[i:]...)
+	return append(merged, right[j:]...)
+}
+
+// minLineOverlap is the shortest repeated text, in runes, that lineOverlap
+// treats as the horizontal overlap rather than a coincidence such as a number
+// or short word that really appears twice.
+const minLineOverlap = 5
+
+// lineOverlap returns the length in runes of the longest prefix of right that
+// matches a suffix of left, ignoring case and spacing, or 0 if it is shorter
+// than minLineOverlap.
+func lineOverlap(left, right string) int {
+	l := []rune(strings.Join(strings.Fields(left), " "))
+	r := []rune(strings.Join(strings.Fields(right), " "))
+	for n := min(len(l), len(r)); n >= minLineOverlap; n-- {
+		if strings.EqualFold(string(l[len(l)-n:]), string(r[:n])) {
+			return n
+		}
+	}
+	return 0
+}
+
+// joinWithoutOverlap joins the left and right parts of a line, skipping the
+// overlap found by lineOverlap. Parts without an overlap are joined with a space.
+func joinWithoutOverlap(left, right string) string {
+	n := lineOverlap(left, right)
+	left = strings.Join(strings.Fields(left), " ")
+	right = strings.Join(strings.Fields(right), " ")
+	if n > 0 {
+		return left + string([]rune(right)[n:])
+	}
+	return left + " " + right
+}
+
+// appendWithoutOverlap appends next to prev, skipping the longest prefix of next
+// that matches a suffix of prev.
+func appendWithoutOverlap(prev, next []string) []string {
+	maxOverlap := min(len(prev), len(next))
+	for n := maxOverlap; n > 0; n-- {
+		if linesEqual(prev[len(prev)-n:], next[:n]) {
+			return append(prev, next[n:]...)
+		}
+	}
+	return append(prev, next...)
+}
+
+func linesEqual(a, b []string) bool {
+	for i := range a {
+		if normalizeLine(a[i]) != normalizeLine(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeLine(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+func splitLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, strings.TrimRight(line, " \t\r"))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,138 @@
+package ollama
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStitchTiles(t *testing.T) {
+	tests := []struct {
+		name       string
+		cols, rows int
+		texts      []string
+		want       string
+	}{
+		{
+			name: "2x2 without overlap",
+			cols: 2, rows: 2,
+			texts: []string{
+				"Invoice number", "12345",
+				"Total due", "99.00 EUR",
+			},
+			want: "Invoice number 12345\nTotal due 99.00 EUR",
+		},
+		{
+			name: "2x2 with overlap",
+			cols: 2, rows: 2,
+			texts: []string{
+				"ACME Corporation\nInvoice number", "Corporation\nnumber 12345",
+				"Invoice number\nTotal due", "number 12345\nal due 99.00 EUR",
+			},
+			want: "ACME Corporation\nInvoice number 12345\nTotal due 99.00 EUR",
+		},
+		{
+			name: "2x2 with a blank tile",
+			cols: 2, rows: 2,
+			texts: []string{
+				"Dear customer,", "",
+				"Kind regards", "Page 1 of 2",
+			},
+			want: "Dear customer,\nKind regards Page 1 of 2",
+		},
+		{
+			name: "2x1 with a line in one tile",
+			cols: 2, rows: 1,
+			texts: []string{
+				"Summary\nInvoice number\nTotal due",
+				"number 12345\nal due 99.00 EUR",
+			},
+			want: "Summary\nInvoice number 12345\nTotal due 99.00 EUR",
+		},
+		{
+			name: "1x3 without overlap",
+			cols: 1, rows: 3,
+			texts: []string{"line one", "line two", "line three"},
+			want:  "line one\nline two\nline three",
+		},
+		{
+			name: "1x3 with overlap",
+			cols: 1, rows: 3,
+			texts: []string{
+				"line one\nline two",
+				"line  two\nline three\nline four",
+				"LINE FOUR\nline five",
+			},
+			want: "line one\nline two\nline three\nline four\nline five",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StitchTiles(tt.texts, tt.cols, tt.rows); got != tt.want {
+				t.Errorf("StitchTiles() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinWithoutOverlap(t *testing.T) {
+	tests := []struct {
+		left, right, want string
+	}{
+		{"The quick brown", "fox jumps", "The quick brown fox jumps"},
+		{"The quick brown fo", "own fox jumps", "The quick brown fox jumps"},
+		{"The quick  brown", "BROWN fox", "The quick brown fox"},
+		{"Größe: groß", "groß und", "Größe: groß groß und"}, // shorter than minLineOverlap
+		{"Größe: sehr groß", "sehr groß und", "Größe: sehr groß und"},
+		{"Room 101", "101 Main St", "Room 101 101 Main St"}, // shorter than minLineOverlap
+	}
+	for _, tt := range tests {
+		if got := joinWithoutOverlap(tt.left, tt.right); got != tt.want {
+			t.Errorf("joinWithoutOverlap(%q, %q) = %q, want %q", tt.left, tt.right, got, tt.want)
+		}
+	}
+}
+
+func TestMergeColumns(t *testing.T) {
+	tests := []struct {
+		name        string
+		left, right []string
+		want        []string
+	}{
+		{"by position without overlap", []string{"a", "b"}, []string{"c", "d"}, []string{"a c", "b d"}},
+		{"extra left line", []string{"Summary", "Invoice number", "Total due"}, []string{"number 12345", "al due 99.00"}, []string{"Summary", "Invoice number 12345", "Total due 99.00"}},
+		{"extra right line", []string{"Invoice number", "Total due"}, []string{"Page 1", "number 12345", "al due 99.00"}, []string{"Page 1", "Invoice number 12345", "Total due 99.00"}},
+		{"empty left", nil, []string{"a"}, []string{"a"}},
+		// Without overlapping text, differing line counts can only be paired
+		// by position.
+		{"extra line without overlap", []string{"Summary", "Invoice", "Total"}, []string{"12345", "99.00"}, []string{"Summary 12345", "Invoice 99.00", "Total"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeColumns(tt.left, tt.right); !slices.Equal(got, tt.want) {
+				t.Errorf("mergeColumns() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendWithoutOverlap(t *testing.T) {
+	tests := []struct {
+		name       string
+		prev, next []string
+		want       []string
+	}{
+		{"no overlap", []string{"a", "b"}, []string{"c", "d"}, []string{"a", "b", "c", "d"}},
+		{"one line", []string{"a", "b"}, []string{"b", "c"}, []string{"a", "b", "c"}},
+		{"two lines", []string{"a", "b", "c"}, []string{"b", "c", "d"}, []string{"a", "b", "c", "d"}},
+		{"case and spacing", []string{"Total  Due"}, []string{"total due", "99"}, []string{"Total  Due", "99"}},
+		{"empty prev", nil, []string{"a"}, []string{"a"}},
+		{"empty next", []string{"a"}, nil, []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := appendWithoutOverlap(slices.Clone(tt.prev), tt.next); !slices.Equal(got, tt.want) {
+				t.Errorf("appendWithoutOverlap() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package paperless
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// Bulk edit methods supported by /api/documents/bulk_edit/.
+const (
+	BulkMethodAddTag             = "add_tag"
+	BulkMethodRemoveTag          = "remove_tag"
+	BulkMethodSetCorrespondent   = "set_correspondent"
+	BulkMethodSetDocumentType    = "set_document_type"
+	BulkMethodModifyCustomFields = "modify_custom_fields"
+)
+
+type bulkEditRequest struct {
+	Documents  []int                  `json:"documents"`
+	Method     string                 `json:"method"`
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+// BulkEdit applies a single bulk edit method to many documents in one request.
+func (c *Client) BulkEdit(ctx context.Context, documentIDs []int, method string, parameters map[string]interface{}) error {
+	if len(documentIDs) == 0 {
+		return nil
+	}
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+	}
+	body, _ := json.Marshal(bulkEditRequest{Documents: documentIDs, Method: method, Parameters: parameters})
+
+	// Every bulk method sets or adds absolute values, so repeating a failed attempt is harmless
+	req, err := http.NewRequestWithContext(withRetrySafe(ctx), http.MethodPost, c.BaseURL+"/api/documents/bulk_edit/", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	c.setHeaders(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("bulk editing documents: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("paperless returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+// BulkAddTag adds a tag to every document.
+func (c *Client) BulkAddTag(ctx context.Context, documentIDs []int, tagID int) error {
+	return c.BulkEdit(ctx, documentIDs, BulkMethodAddTag, map[string]interface{}{"tag": tagID})
+}
+
+// BulkRemoveTag removes a tag from every document.
+func (c *Client) BulkRemoveTag(ctx context.Context, documentIDs []int, tagID int) error {
+	return c.BulkEdit(ctx, documentIDs, BulkMethodRemoveTag, map[string]interface{}{"tag": tagID})
+}
+
+// BulkSetCorrespondent sets the correspondent of every document.
+func (c *Client) BulkSetCorrespondent(ctx context.Context, documentIDs []int, correspondentID int) error {
+	return c.BulkEdit(ctx, documentIDs, BulkMethodSetCorrespondent, map[string]interface{}{"correspondent": correspondentID})
+}
+
+// BulkSetDocumentType sets the document type of every document.
+func (c *Client) BulkSetDocumentType(ctx context.Context, documentIDs []int, documentTypeID int) error {
+	return c.BulkEdit(ctx, documentIDs, BulkMethodSetDocumentType, map[string]interface{}{"document_type": documentTypeID})
+}
+
+// BulkModifyCustomFields sets the given custom field values (keyed by field ID)
+// and removes the listed fields on every document.
+func (c *Client) BulkModifyCustomFields(ctx context.Context, documentIDs []int, add map[int]interface{}, remove []int) error {
+	addByID := make(map[string]interface{}, len(add))
+	for id, v := range add {
+		addByID[strconv.Itoa(id)] = v
+	}
+	if remove == nil {
+		remove = []int{}
+	}
+	return c.BulkEdit(ctx, documentIDs, BulkMethodModifyCustomFields, map[string]interface{}{
+		"add_custom_fields":    addByID,
+		"remove_custom_fields": remove,
+	})
+}
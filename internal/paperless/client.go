@@ -8,76 +8,142 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
 )
 
 type Client struct {
 	BaseURL string
 	Token   string
 	HTTP    *http.Client
+	// PageSize is the page_size requested from paginated list endpoints.
+	PageSize int
+	// APIVersion is the REST API version requested in the Accept header. 0 sends
+	// none, leaving the server's default. Set by Detect.
+	APIVersion int
+	// Server describes the detected server. nil until Detect has run.
+	Server *ServerInfo
 }
 
+// DefaultPageSize is the page size used by NewClient.
+const DefaultPageSize = 100
+
+// Document is a Paperless-ngx document as returned by the documents API.
+// Content, the OCR text, is only fetched by GetDocument.
 type Document struct {
-	ID    int    `json:"id"`
-	Title string `json:"title"`
+	ID                  int                `json:"id"`
+	Title               string             `json:"title"`
+	Content             string             `json:"content,omitempty"`
+	Correspondent       *int               `json:"correspondent"`
+	DocumentType        *int               `json:"document_type"`
+	StoragePath         *int               `json:"storage_path"`
+	Tags                []int              `json:"tags"`
+	Created             string             `json:"created,omitempty"`
+	Added               string             `json:"added,omitempty"`
+	CustomFields        []CustomFieldValue `json:"custom_fields"`
+	Owner               *int               `json:"owner"`
+	ArchiveSerialNumber *int               `json:"archive_serial_number"`
+	MimeType            string             `json:"mime_type,omitempty"`
+	PageCount           *int               `json:"page_count"`
 }
 
-type listResponse struct {
-	Count   int        `json:"count"`
-	Next    *string    `json:"next"`
-	Results []Document `json:"results"`
+// streamFields is the fields= projection requested by streamDocuments. It leaves
+// out content, the full OCR text, which lists never need. custom_fields is
+// included because callers merge their values into updates.
+const streamFields = "id,title,correspondent,document_type,storage_path,tags,created,added,custom_fields,owner,archive_serial_number,mime_type,page_count"
+
+// documentFields is the fields= projection requested by GetDocument.
+const documentFields = streamFields + ",content"
+
+// listResponse is the paginated envelope returned by Paperless-ngx list endpoints.
+type listResponse[T any] struct {
+	Count   int     `json:"count"`
+	Next    *string `json:"next"`
+	Results []T     `json:"results"`
+	// All holds the IDs of every matching result. Only the documents endpoint
+	// returns it, on every page.
+	All []int `json:"all,omitempty"`
 }
 
 type CustomField struct {
-	ID       int    `json:"id"`
-	Name     string `json:"name"`
-	DataType string `json:"data_type"`
+	ID        int                   `json:"id"`
+	Name      string                `json:"name"`
+	DataType  string                `json:"data_type"`
+	ExtraData *CustomFieldExtraData `json:"extra_data,omitempty"`
 }
 
-type customFieldListResponse struct {
-	Count   int           `json:"count"`
-	Next    *string       `json:"next"`
-	Results []CustomField `json:"results"`
+func NewClient(baseURL, token string) *Client {
+	return NewClientWithOptions(baseURL, token, DefaultTransportOptions())
 }
 
-func NewClient(baseURL, token string) *Client {
+// NewClientWithOptions creates a client whose requests use the given timeout,
+// retry and rate limit settings.
+func NewClientWithOptions(baseURL, token string, opts TransportOptions) *Client {
 	return &Client{
-		BaseURL: baseURL,
-		Token:   token,
-		HTTP:    &http.Client{},
+		BaseURL:  baseURL,
+		Token:    token,
+		HTTP:     &http.Client{Transport: newRetryTransport(http.DefaultTransport, opts)},
+		PageSize: DefaultPageSize,
 	}
 }
 
-// ListCustomFields fetches all custom field definitions from Paperless-ngx.
-func (c *Client) ListCustomFields(ctx context.Context) ([]CustomField, error) {
-	var all []CustomField
-	reqURL := c.BaseURL + "/api/custom_fields/"
+// listAll fetches every page of a paginated list endpoint. path is relative to
+// BaseURL; query may be nil. page_size is added from c.PageSize when set.
+func listAll[T any](ctx context.Context, c *Client, path string, query url.Values) ([]T, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	if c.PageSize > 0 {
+		query.Set("page_size", strconv.Itoa(c.PageSize))
+	}
+
+	var all []T
+	err := eachPage(ctx, c, path, query, func(page listResponse[T]) bool {
+		all = append(all, page.Results...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// eachPage fetches the pages of a list endpoint one at a time, following the
+// next links until there are no more pages or yield returns false.
+func eachPage[T any](ctx context.Context, c *Client, path string, query url.Values, yield func(listResponse[T]) bool) error {
+	reqURL := c.BaseURL + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
 
 	for reqURL != "" {
 		req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
 		if err != nil {
-			return nil, fmt.Errorf("creating request: %w", err)
+			return fmt.Errorf("creating request: %w", err)
 		}
-		req.Header.Set("Authorization", "Token "+c.Token)
+		c.setHeaders(req)
 
 		resp, err := c.HTTP.Do(req)
 		if err != nil {
-			return nil, fmt.Errorf("fetching custom fields: %w", err)
+			return fmt.Errorf("fetching %s: %w", path, err)
 		}
 
 		if resp.StatusCode != http.StatusOK {
 			body, _ := io.ReadAll(resp.Body)
 			resp.Body.Close()
-			return nil, fmt.Errorf("paperless returned status %d: %s", resp.StatusCode, string(body))
+			return fmt.Errorf("paperless returned status %d: %s", resp.StatusCode, string(body))
 		}
 
-		var page customFieldListResponse
+		var page listResponse[T]
 		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
 			resp.Body.Close()
-			return nil, fmt.Errorf("decoding response: %w", err)
+			return fmt.Errorf("decoding response: %w", err)
 		}
 		resp.Body.Close()
 
-		all = append(all, page.Results...)
+		if !yield(page) {
+			return nil
+		}
 
 		if page.Next != nil {
 			reqURL = *page.Next
@@ -86,49 +152,24 @@ func (c *Client) ListCustomFields(ctx context.Context) ([]CustomField, error) {
 		}
 	}
 
-	return all, nil
+	return nil
+}
+
+// ListCustomFields fetches all custom field definitions from Paperless-ngx.
+func (c *Client) ListCustomFields(ctx context.Context) ([]CustomField, error) {
+	return listAll[CustomField](ctx, c, "/api/custom_fields/", nil)
 }
 
 // CreateCustomField creates a new custom field definition in Paperless-ngx.
 func (c *Client) CreateCustomField(ctx context.Context, name, dataType string) (CustomField, error) {
-	body, _ := json.Marshal(map[string]string{"name": name, "data_type": dataType})
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/api/custom_fields/", bytes.NewReader(body))
-	if err != nil {
-		return CustomField{}, fmt.Errorf("creating request: %w", err)
-	}
-	req.Header.Set("Authorization", "Token "+c.Token)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.HTTP.Do(req)
-	if err != nil {
-		return CustomField{}, fmt.Errorf("creating custom field: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusCreated {
-		respBody, _ := io.ReadAll(resp.Body)
-		return CustomField{}, fmt.Errorf("paperless returned status %d: %s", resp.StatusCode, string(respBody))
-	}
-
-	var cf CustomField
-	if err := json.NewDecoder(resp.Body).Decode(&cf); err != nil {
-		return CustomField{}, fmt.Errorf("decoding response: %w", err)
-	}
-	return cf, nil
+	return c.createCustomField(ctx, CustomFieldSpec{Name: name, DataType: dataType})
 }
 
-// EnsureCustomField returns the custom field with the given name, creating it if it doesn't exist.
+// EnsureCustomField returns the custom field with the given name, creating it if it
+// doesn't exist. An existing field of a different data type is reported as a
+// *DataTypeMismatchError.
 func (c *Client) EnsureCustomField(ctx context.Context, name, dataType string) (CustomField, error) {
-	fields, err := c.ListCustomFields(ctx)
-	if err != nil {
-		return CustomField{}, fmt.Errorf("listing custom fields: %w", err)
-	}
-	for _, f := range fields {
-		if f.Name == name {
-			return f, nil
-		}
-	}
-	return c.CreateCustomField(ctx, name, dataType)
+	return c.EnsureCustomFieldSpec(ctx, CustomFieldSpec{Name: name, DataType: dataType}, false)
 }
 
 type DocumentType struct {
@@ -136,52 +177,9 @@ type DocumentType struct {
 	Name string `json:"name"`
 }
 
-type documentTypeListResponse struct {
-	Count   int            `json:"count"`
-	Next    *string        `json:"next"`
-	Results []DocumentType `json:"results"`
-}
-
 // ListDocumentTypes fetches all document types from Paperless-ngx.
 func (c *Client) ListDocumentTypes(ctx context.Context) ([]DocumentType, error) {
-	var all []DocumentType
-	reqURL := c.BaseURL + "/api/document_types/?fields=id,name"
-
-	for reqURL != "" {
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
-		if err != nil {
-			return nil, fmt.Errorf("creating request: %w", err)
-		}
-		req.Header.Set("Authorization", "Token "+c.Token)
-
-		resp, err := c.HTTP.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("fetching document types: %w", err)
-		}
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			return nil, fmt.Errorf("paperless returned status %d: %s", resp.StatusCode, string(body))
-		}
-
-		var page documentTypeListResponse
-		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
-			resp.Body.Close()
-			return nil, fmt.Errorf("decoding response: %w", err)
-		}
-		resp.Body.Close()
-
-		all = append(all, page.Results...)
-
-		if page.Next != nil {
-			reqURL = *page.Next
-		} else {
-			reqURL = ""
-		}
-	}
-
-	return all, nil
+	return listAll[DocumentType](ctx, c, "/api/document_types/", url.Values{"fields": {"id,name"}})
 }
 
 type Correspondent struct {
@@ -189,52 +187,9 @@ type Correspondent struct {
 	Name string `json:"name"`
 }
 
-type correspondentListResponse struct {
-	Count   int             `json:"count"`
-	Next    *string         `json:"next"`
-	Results []Correspondent `json:"results"`
-}
-
 // ListCorrespondents fetches all correspondents from Paperless-ngx.
 func (c *Client) ListCorrespondents(ctx context.Context) ([]Correspondent, error) {
-	var all []Correspondent
-	reqURL := c.BaseURL + "/api/correspondents/?fields=id,name"
-
-	for reqURL != "" {
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
-		if err != nil {
-			return nil, fmt.Errorf("creating request: %w", err)
-		}
-		req.Header.Set("Authorization", "Token "+c.Token)
-
-		resp, err := c.HTTP.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("fetching correspondents: %w", err)
-		}
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			return nil, fmt.Errorf("paperless returned status %d: %s", resp.StatusCode, string(body))
-		}
-
-		var page correspondentListResponse
-		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
-			resp.Body.Close()
-			return nil, fmt.Errorf("decoding response: %w", err)
-		}
-		resp.Body.Close()
-
-		all = append(all, page.Results...)
-
-		if page.Next != nil {
-			reqURL = *page.Next
-		} else {
-			reqURL = ""
-		}
-	}
-
-	return all, nil
+	return listAll[Correspondent](ctx, c, "/api/correspondents/", url.Values{"fields": {"id,name"}})
 }
 
 // CreateCorrespondent creates a new correspondent in Paperless-ngx.
@@ -244,7 +199,7 @@ func (c *Client) CreateCorrespondent(ctx context.Context, name string) (Correspo
 	if err != nil {
 		return Correspondent{}, fmt.Errorf("creating request: %w", err)
 	}
-	req.Header.Set("Authorization", "Token "+c.Token)
+	c.setHeaders(req)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.HTTP.Do(req)
@@ -283,52 +238,9 @@ type Tag struct {
 	Name string `json:"name"`
 }
 
-type tagListResponse struct {
-	Count   int     `json:"count"`
-	Next    *string `json:"next"`
-	Results []Tag   `json:"results"`
-}
-
 // ListTags fetches all tags from Paperless-ngx.
 func (c *Client) ListTags(ctx context.Context) ([]Tag, error) {
-	var all []Tag
-	reqURL := c.BaseURL + "/api/tags/?fields=id,name"
-
-	for reqURL != "" {
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
-		if err != nil {
-			return nil, fmt.Errorf("creating request: %w", err)
-		}
-		req.Header.Set("Authorization", "Token "+c.Token)
-
-		resp, err := c.HTTP.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("fetching tags: %w", err)
-		}
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			return nil, fmt.Errorf("paperless returned status %d: %s", resp.StatusCode, string(body))
-		}
-
-		var page tagListResponse
-		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
-			resp.Body.Close()
-			return nil, fmt.Errorf("decoding response: %w", err)
-		}
-		resp.Body.Close()
-
-		all = append(all, page.Results...)
-
-		if page.Next != nil {
-			reqURL = *page.Next
-		} else {
-			reqURL = ""
-		}
-	}
-
-	return all, nil
+	return listAll[Tag](ctx, c, "/api/tags/", url.Values{"fields": {"id,name"}})
 }
 
 // CreateTag creates a new tag in Paperless-ngx.
@@ -338,7 +250,7 @@ func (c *Client) CreateTag(ctx context.Context, name string) (Tag, error) {
 	if err != nil {
 		return Tag{}, fmt.Errorf("creating request: %w", err)
 	}
-	req.Header.Set("Authorization", "Token "+c.Token)
+	c.setHeaders(req)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.HTTP.Do(req)
@@ -372,32 +284,91 @@ func (c *Client) EnsureTag(ctx context.Context, name string, existing map[string
 	return tag.ID, nil
 }
 
+type StoragePath struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// ListStoragePaths fetches all storage paths from Paperless-ngx.
+func (c *Client) ListStoragePaths(ctx context.Context) ([]StoragePath, error) {
+	return listAll[StoragePath](ctx, c, "/api/storage_paths/", url.Values{"fields": {"id,name,path"}})
+}
+
+type User struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+// ListUsers fetches all users from Paperless-ngx.
+func (c *Client) ListUsers(ctx context.Context) ([]User, error) {
+	return listAll[User](ctx, c, "/api/users/", nil)
+}
+
+type Group struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// ListGroups fetches all groups from Paperless-ngx.
+func (c *Client) ListGroups(ctx context.Context) ([]Group, error) {
+	return listAll[Group](ctx, c, "/api/groups/", nil)
+}
+
+// PermissionSet lists the users and groups granted a permission.
+type PermissionSet struct {
+	Users  []int `json:"users"`
+	Groups []int `json:"groups"`
+}
+
+// Permissions are the object-level view and change permissions of a document.
+type Permissions struct {
+	View   PermissionSet `json:"view"`
+	Change PermissionSet `json:"change"`
+}
+
 // CustomFieldValue represents a custom field value to set on a document.
 type CustomFieldValue struct {
 	Field int         `json:"field"`
 	Value interface{} `json:"value"`
 }
 
+// MergeCustomFields returns current with the values in set added or replaced.
+// A PATCH replaces a document's whole custom field list, so an update that sets
+// some fields sends the merged list to keep the others.
+func MergeCustomFields(current, set []CustomFieldValue) []CustomFieldValue {
+	merged := make([]CustomFieldValue, 0, len(current)+len(set))
+	for _, cf := range current {
+		if !slices.ContainsFunc(set, func(s CustomFieldValue) bool { return s.Field == cf.Field }) {
+			merged = append(merged, cf)
+		}
+	}
+	return append(merged, set...)
+}
+
 // DocumentUpdate holds the fields to update on a document via PATCH.
 type DocumentUpdate struct {
-	Title         *string            `json:"title,omitempty"`
-	Content       *string            `json:"content,omitempty"`
-	DocumentType  *int               `json:"document_type,omitempty"`
-	Correspondent *int               `json:"correspondent,omitempty"`
-	Tags          []int              `json:"tags,omitempty"`
-	Created       *string            `json:"created,omitempty"`
-	CustomFields  []CustomFieldValue `json:"custom_fields,omitempty"`
+	Title          *string            `json:"title,omitempty"`
+	Content        *string            `json:"content,omitempty"`
+	DocumentType   *int               `json:"document_type,omitempty"`
+	Correspondent  *int               `json:"correspondent,omitempty"`
+	StoragePath    *int               `json:"storage_path,omitempty"`
+	Tags           []int              `json:"tags,omitempty"`
+	Created        *string            `json:"created,omitempty"`
+	CustomFields   []CustomFieldValue `json:"custom_fields,omitempty"`
+	Owner          *int               `json:"owner,omitempty"`
+	SetPermissions *Permissions       `json:"set_permissions,omitempty"`
 }
 
 // UpdateDocument patches a document with the provided fields.
 func (c *Client) UpdateDocument(ctx context.Context, documentID int, update DocumentUpdate) error {
 	body, _ := json.Marshal(update)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, fmt.Sprintf("%s/api/documents/%d/", c.BaseURL, documentID), bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(withRetrySafe(ctx), http.MethodPatch, fmt.Sprintf("%s/api/documents/%d/", c.BaseURL, documentID), bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
 	}
-	req.Header.Set("Authorization", "Token "+c.Token)
+	c.setHeaders(req)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.HTTP.Do(req)
@@ -414,6 +385,21 @@ func (c *Client) UpdateDocument(ctx context.Context, documentID int, update Docu
 	return nil
 }
 
+// GetDocument fetches a document by ID.
+func (c *Client) GetDocument(ctx context.Context, documentID int) (Document, error) {
+	resp, err := c.get(ctx, fmt.Sprintf("/api/documents/%d/?fields=%s", documentID, documentFields))
+	if err != nil {
+		return Document{}, err
+	}
+	defer resp.Body.Close()
+
+	var doc Document
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return Document{}, fmt.Errorf("decoding response: %w", err)
+	}
+	return doc, nil
+}
+
 // DownloadDocument downloads the original file for a document by ID.
 func (c *Client) DownloadDocument(ctx context.Context, documentID int) ([]byte, error) {
 	reqURL := fmt.Sprintf("%s/api/documents/%d/download/", c.BaseURL, documentID)
@@ -421,7 +407,7 @@ func (c *Client) DownloadDocument(ctx context.Context, documentID int) ([]byte,
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
-	req.Header.Set("Authorization", "Token "+c.Token)
+	c.setHeaders(req)
 
 	resp, err := c.HTTP.Do(req)
 	if err != nil {
@@ -440,88 +426,18 @@ func (c *Client) DownloadDocument(ctx context.Context, documentID int) ([]byte,
 // ListUnprocessedDocuments fetches documents where the custom field is null or less than processID,
 // excluding any documents where skipFieldName is set to true.
 func (c *Client) ListUnprocessedDocuments(ctx context.Context, fieldName string, processID int, skipFieldName string) ([]Document, error) {
-	// Query 1: field does not exist on the document
-	existsQuery, _ := json.Marshal([]interface{}{fieldName, "exists", false})
-	nullDocs, err := c.listDocuments(ctx, fmt.Sprintf("&custom_field_query=%s", url.QueryEscape(string(existsQuery))))
-	if err != nil {
-		return nil, fmt.Errorf("querying null documents: %w", err)
-	}
-
-	// Query 2: field value < processID
-	ltQuery, _ := json.Marshal([]interface{}{fieldName, "lt", processID})
-	ltDocs, err := c.listDocuments(ctx, fmt.Sprintf("&custom_field_query=%s", url.QueryEscape(string(ltQuery))))
-	if err != nil {
-		return nil, fmt.Errorf("querying lt documents: %w", err)
-	}
-
-	// Find documents to skip (llm-skip == true)
-	skipIDs := make(map[int]bool)
-	if skipFieldName != "" {
-		skipQuery, _ := json.Marshal([]interface{}{skipFieldName, "exact", true})
-		skipDocs, err := c.listDocuments(ctx, fmt.Sprintf("&custom_field_query=%s", url.QueryEscape(string(skipQuery))))
+	var docs []Document
+	for doc, err := range c.UnprocessedDocuments(ctx, DocumentFilter{}, fieldName, processID, skipFieldName) {
 		if err != nil {
-			return nil, fmt.Errorf("querying skip documents: %w", err)
-		}
-		for _, doc := range skipDocs {
-			skipIDs[doc.ID] = true
-		}
-	}
-
-	// Deduplicate and exclude skipped
-	seen := make(map[int]bool)
-	var result []Document
-	for _, doc := range append(nullDocs, ltDocs...) {
-		if !seen[doc.ID] && !skipIDs[doc.ID] {
-			seen[doc.ID] = true
-			result = append(result, doc)
+			return nil, err
 		}
+		docs = append(docs, doc)
 	}
-
-	return result, nil
+	return docs, nil
 }
 
-// ListDocuments fetches all documents from Paperless-ngx, handling pagination.
+// ListDocuments fetches the ID and title of all documents from Paperless-ngx,
+// handling pagination.
 func (c *Client) ListDocuments(ctx context.Context) ([]Document, error) {
-	return c.listDocuments(ctx, "")
-}
-
-func (c *Client) listDocuments(ctx context.Context, extraQuery string) ([]Document, error) {
-	var all []Document
-	reqURL := c.BaseURL + "/api/documents/?fields=id,title" + extraQuery
-
-	for reqURL != "" {
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
-		if err != nil {
-			return nil, fmt.Errorf("creating request: %w", err)
-		}
-		req.Header.Set("Authorization", "Token "+c.Token)
-
-		resp, err := c.HTTP.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("fetching documents: %w", err)
-		}
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			return nil, fmt.Errorf("paperless returned status %d: %s", resp.StatusCode, string(body))
-		}
-
-		var page listResponse
-		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
-			resp.Body.Close()
-			return nil, fmt.Errorf("decoding response: %w", err)
-		}
-		resp.Body.Close()
-
-		all = append(all, page.Results...)
-
-		if page.Next != nil {
-			reqURL = *page.Next
-		} else {
-			reqURL = ""
-		}
-	}
-
-	return all, nil
+	return listAll[Document](ctx, c, "/api/documents/", url.Values{"fields": {"id,title"}})
 }
@@ -0,0 +1,279 @@
+package paperless
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Custom field data types.
+const (
+	DataTypeString       = "string"
+	DataTypeURL          = "url"
+	DataTypeDate         = "date"
+	DataTypeBoolean      = "boolean"
+	DataTypeInteger      = "integer"
+	DataTypeFloat        = "float"
+	DataTypeMonetary     = "monetary"
+	DataTypeDocumentLink = "documentlink"
+	DataTypeSelect       = "select"
+	DataTypeLongText     = "longtext"
+)
+
+// CustomFieldSpec describes a custom field to create or verify.
+//
+// The processor's own fields (llm-process-id, llm-summary, llm-model, llm-skip)
+// are never select fields. Select support is library API for callers that keep
+// structured values such as a payment status in their own fields: they ensure
+// the field with its SelectOptions and set values with CustomField.SelectValue.
+// Within the processor it is only used to migrate fields to the select type.
+type CustomFieldSpec struct {
+	Name     string
+	DataType string
+	// SelectOptions are the option labels of a select field.
+	SelectOptions []string
+}
+
+// CustomFieldExtraData holds data-type specific settings of a custom field.
+type CustomFieldExtraData struct {
+	SelectOptions []SelectOption `json:"select_options,omitempty"`
+}
+
+// SelectOption is one option of a select field. Before API version 7 options are
+// plain labels and values reference them by index; from version 7 on they are
+// objects and values reference the option ID.
+type SelectOption struct {
+	ID    string `json:"id,omitempty"`
+	Label string `json:"label"`
+}
+
+func (o *SelectOption) UnmarshalJSON(data []byte) error {
+	var label string
+	if err := json.Unmarshal(data, &label); err == nil {
+		*o = SelectOption{Label: label}
+		return nil
+	}
+	type plain SelectOption
+	return json.Unmarshal(data, (*plain)(o))
+}
+
+// SelectValue returns the value that selects the option with the given label
+// (case-insensitive): the option ID, or its index on servers without option IDs.
+// Use it to build the CustomFieldValue of a select field, since Paperless-ngx
+// does not accept the label itself.
+func (f CustomField) SelectValue(label string) (interface{}, bool) {
+	if f.ExtraData == nil {
+		return nil, false
+	}
+	for i, o := range f.ExtraData.SelectOptions {
+		if strings.EqualFold(o.Label, label) {
+			if o.ID != "" {
+				return o.ID, true
+			}
+			return i, true
+		}
+	}
+	return nil, false
+}
+
+// DataTypeMismatchError reports an existing custom field whose data type differs
+// from the one required.
+type DataTypeMismatchError struct {
+	Name string
+	Want string
+	Got  string
+}
+
+func (e *DataTypeMismatchError) Error() string {
+	return fmt.Sprintf("custom field '%s' has data type %s, expected %s (delete or rename it, or enable migration)", e.Name, e.Got, e.Want)
+}
+
+// EnsureCustomFieldSpec returns the custom field named spec.Name, creating it if it
+// doesn't exist. Select options missing from an existing select field are added.
+//
+// An existing field of a different data type is a *DataTypeMismatchError unless
+// migrate is set. Paperless-ngx cannot change a field's data type, so migration
+// renames the existing field to "<name> (<old type>)", creates a new field and
+// copies every value that converts to the new type. The renamed field is kept.
+func (c *Client) EnsureCustomFieldSpec(ctx context.Context, spec CustomFieldSpec, migrate bool) (CustomField, error) {
+	fields, err := c.ListCustomFields(ctx)
+	if err != nil {
+		return CustomField{}, fmt.Errorf("listing custom fields: %w", err)
+	}
+	for _, f := range fields {
+		if f.Name != spec.Name {
+			continue
+		}
+		if f.DataType != spec.DataType {
+			if !migrate {
+				return CustomField{}, &DataTypeMismatchError{Name: f.Name, Want: spec.DataType, Got: f.DataType}
+			}
+			return c.migrateCustomField(ctx, f, spec)
+		}
+		if spec.DataType == DataTypeSelect {
+			return c.addSelectOptions(ctx, f, spec.SelectOptions)
+		}
+		return f, nil
+	}
+	return c.createCustomField(ctx, spec)
+}
+
+func (c *Client) createCustomField(ctx context.Context, spec CustomFieldSpec) (CustomField, error) {
+	body := map[string]interface{}{"name": spec.Name, "data_type": spec.DataType}
+	if spec.DataType == DataTypeSelect {
+		var options []SelectOption
+		for _, label := range spec.SelectOptions {
+			options = append(options, SelectOption{Label: label})
+		}
+		body["extra_data"] = map[string]interface{}{"select_options": c.selectOptionsJSON(options)}
+	}
+	return c.sendCustomField(ctx, http.MethodPost, c.BaseURL+"/api/custom_fields/", body, http.StatusCreated)
+}
+
+// addSelectOptions appends the labels missing from a select field's options.
+func (c *Client) addSelectOptions(ctx context.Context, f CustomField, labels []string) (CustomField, error) {
+	var options []SelectOption
+	if f.ExtraData != nil {
+		options = f.ExtraData.SelectOptions
+	}
+	added := false
+	for _, label := range labels {
+		if _, ok := f.SelectValue(label); !ok {
+			options = append(options, SelectOption{Label: label})
+			added = true
+		}
+	}
+	if !added {
+		return f, nil
+	}
+	return c.updateCustomField(ctx, f.ID, map[string]interface{}{
+		"extra_data": map[string]interface{}{"select_options": c.selectOptionsJSON(options)},
+	})
+}
+
+// selectOptionsJSON encodes options for the negotiated API version: objects with
+// option IDs (new options get one from the server) or plain labels.
+func (c *Client) selectOptionsJSON(options []SelectOption) interface{} {
+	if options == nil {
+		options = []SelectOption{}
+	}
+	if c.Supports(CapSelectOptionIDs) {
+		return options
+	}
+	labels := make([]string, len(options))
+	for i, o := range options {
+		labels[i] = o.Label
+	}
+	return labels
+}
+
+func (c *Client) updateCustomField(ctx context.Context, id int, body map[string]interface{}) (CustomField, error) {
+	return c.sendCustomField(withRetrySafe(ctx), http.MethodPatch, fmt.Sprintf("%s/api/custom_fields/%d/", c.BaseURL, id), body, http.StatusOK)
+}
+
+func (c *Client) sendCustomField(ctx context.Context, method, reqURL string, body map[string]interface{}, wantStatus int) (CustomField, error) {
+	data, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, bytes.NewReader(data))
+	if err != nil {
+		return CustomField{}, fmt.Errorf("creating request: %w", err)
+	}
+	c.setHeaders(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return CustomField{}, fmt.Errorf("saving custom field: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantStatus {
+		respBody, _ := io.ReadAll(resp.Body)
+		return CustomField{}, fmt.Errorf("paperless returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var cf CustomField
+	if err := json.NewDecoder(resp.Body).Decode(&cf); err != nil {
+		return CustomField{}, fmt.Errorf("decoding response: %w", err)
+	}
+	return cf, nil
+}
+
+// migrateCustomField replaces old with a new field of spec's data type, copying
+// the values that convert.
+func (c *Client) migrateCustomField(ctx context.Context, old CustomField, spec CustomFieldSpec) (CustomField, error) {
+	renamed := fmt.Sprintf("%s (%s)", old.Name, old.DataType)
+	slog.InfoContext(ctx, "migrating custom field", "field", old.Name, "from", old.DataType, "to", spec.DataType, "renamed", renamed)
+	if _, err := c.updateCustomField(ctx, old.ID, map[string]interface{}{"name": renamed}); err != nil {
+		return CustomField{}, fmt.Errorf("renaming custom field '%s': %w", old.Name, err)
+	}
+
+	field, err := c.createCustomField(ctx, spec)
+	if err != nil {
+		return CustomField{}, fmt.Errorf("creating custom field '%s': %w", spec.Name, err)
+	}
+
+	copied, dropped := 0, 0
+	query := url.Values{"custom_fields__id__all": {strconv.Itoa(old.ID)}}
+	for doc, err := range c.streamDocuments(ctx, query) {
+		if err != nil {
+			return field, fmt.Errorf("listing documents with '%s': %w", renamed, err)
+		}
+		var value interface{}
+		ok := false
+		for _, cf := range doc.CustomFields {
+			if cf.Field == old.ID {
+				value, ok = convertFieldValue(cf.Value, field)
+			}
+		}
+		if !ok {
+			dropped++
+			continue
+		}
+		update := DocumentUpdate{CustomFields: append(doc.CustomFields, CustomFieldValue{Field: field.ID, Value: value})}
+		if err := c.UpdateDocument(ctx, doc.ID, update); err != nil {
+			return field, fmt.Errorf("copying '%s' on document %d: %w", spec.Name, doc.ID, err)
+		}
+		copied++
+	}
+	slog.InfoContext(ctx, "migrated custom field", "field", spec.Name, "copied", copied, "not_convertible", dropped, "to", spec.DataType)
+	return field, nil
+}
+
+// convertFieldValue converts a custom field value to the data type of field.
+func convertFieldValue(v interface{}, field CustomField) (interface{}, bool) {
+	if v == nil {
+		return nil, false
+	}
+	s := strings.TrimSpace(fmt.Sprint(v))
+	if f, ok := v.(float64); ok {
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	switch field.DataType {
+	case DataTypeString, DataTypeLongText, DataTypeURL:
+		return s, s != ""
+	case DataTypeInteger:
+		n, err := strconv.Atoi(s)
+		return n, err == nil
+	case DataTypeFloat:
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err == nil
+	case DataTypeBoolean:
+		b, err := strconv.ParseBool(s)
+		return b, err == nil
+	case DataTypeDate:
+		_, err := time.Parse(time.DateOnly, s)
+		return s, err == nil
+	case DataTypeSelect:
+		return field.SelectValue(s)
+	}
+	return nil, false
+}
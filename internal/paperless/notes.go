@@ -0,0 +1,92 @@
+package paperless
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Note is a note attached to a Paperless-ngx document.
+type Note struct {
+	ID      int      `json:"id"`
+	Note    string   `json:"note"`
+	Created string   `json:"created"`
+	User    NoteUser `json:"user"`
+}
+
+// NoteUser is the author of a note. Older Paperless-ngx versions return only
+// the user ID, newer ones an object with the username.
+type NoteUser struct {
+	ID       int    `json:"id"`
+	Username string `json:"username,omitempty"`
+}
+
+func (u *NoteUser) UnmarshalJSON(data []byte) error {
+	if id, err := strconv.Atoi(string(data)); err == nil {
+		u.ID = id
+		return nil
+	}
+	type plain NoteUser
+	return json.Unmarshal(data, (*plain)(u))
+}
+
+// ListNotes fetches all notes on a document.
+func (c *Client) ListNotes(ctx context.Context, documentID int) ([]Note, error) {
+	return c.doNotes(ctx, http.MethodGet, c.notesURL(documentID), nil)
+}
+
+// AddNote adds a note to a document and returns the document's notes afterwards.
+func (c *Client) AddNote(ctx context.Context, documentID int, text string) ([]Note, error) {
+	body, _ := json.Marshal(map[string]string{"note": text})
+	return c.doNotes(ctx, http.MethodPost, c.notesURL(documentID), body)
+}
+
+// DeleteNote removes a note from a document.
+func (c *Client) DeleteNote(ctx context.Context, documentID, noteID int) error {
+	reqURL := c.notesURL(documentID) + "?" + url.Values{"id": {strconv.Itoa(noteID)}}.Encode()
+	_, err := c.doNotes(ctx, http.MethodDelete, reqURL, nil)
+	return err
+}
+
+func (c *Client) notesURL(documentID int) string {
+	return fmt.Sprintf("%s/api/documents/%d/notes/", c.BaseURL, documentID)
+}
+
+// doNotes performs a request against the notes endpoint, which responds with the
+// document's full note list for every method.
+func (c *Client) doNotes(ctx context.Context, method, reqURL string, body []byte) ([]Note, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	c.setHeaders(req)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting notes: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("paperless returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var notes []Note
+	if err := json.NewDecoder(resp.Body).Decode(&notes); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return notes, nil
+}
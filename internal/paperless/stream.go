@@ -0,0 +1,210 @@
+package paperless
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/url"
+	"strconv"
+)
+
+// UnprocessedQuery builds a custom_field_query matching documents where fieldName
+// is missing, empty or less than processID and, if skipFieldName is set, that
+// field is not true:
+//
+//	["AND", [["OR", [[field, "exists", false], [field, "isnull", true], [field, "lt", processID]]], ["NOT", [skip, "exact", true]]]]
+func UnprocessedQuery(fieldName string, processID int, skipFieldName string) json.RawMessage {
+	var query interface{} = []interface{}{"OR", []interface{}{
+		[]interface{}{fieldName, "exists", false},
+		[]interface{}{fieldName, "isnull", true},
+		[]interface{}{fieldName, "lt", processID},
+	}}
+	if skipFieldName != "" {
+		query = []interface{}{"AND", []interface{}{
+			query,
+			[]interface{}{"NOT", []interface{}{skipFieldName, "exact", true}},
+		}}
+	}
+	data, _ := json.Marshal(query)
+	return data
+}
+
+// UnprocessedDocuments streams the documents selected by the filter that have not
+// been processed at processID and are not marked to skip (see UnprocessedQuery).
+// Documents are ordered by the filter's ordering, or by ID when none is set.
+func (c *Client) UnprocessedDocuments(ctx context.Context, filter DocumentFilter, fieldName string, processID int, skipFieldName string) iter.Seq2[Document, error] {
+	return func(yield func(Document, error) bool) {
+		query, err := c.filterValues(ctx, filter)
+		if err != nil {
+			yield(Document{}, err)
+			return
+		}
+
+		if !c.Supports(CapCustomFieldQuery) {
+			c.filterUnprocessed(ctx, query, fieldName, processID, skipFieldName, yield)
+			return
+		}
+
+		unprocessed := UnprocessedQuery(fieldName, processID, skipFieldName)
+		if existing := query.Get("custom_field_query"); existing != "" {
+			// Keep a saved view's own custom field query
+			combined, _ := json.Marshal([]interface{}{"AND", []json.RawMessage{json.RawMessage(existing), unprocessed}})
+			unprocessed = combined
+		}
+		query.Set("custom_field_query", string(unprocessed))
+		if query.Get("ordering") == "" {
+			query.Set("ordering", "id")
+		}
+
+		for doc, err := range c.streamDocuments(ctx, query) {
+			if !yield(doc, err) {
+				return
+			}
+		}
+	}
+}
+
+// filterUnprocessed is the fallback for servers without custom_field_query: it
+// streams every document matching query and drops processed and skipped ones
+// client-side.
+func (c *Client) filterUnprocessed(ctx context.Context, query url.Values, fieldName string, processID int, skipFieldName string, yield func(Document, error) bool) {
+	fields, err := c.ListCustomFields(ctx)
+	if err != nil {
+		yield(Document{}, fmt.Errorf("listing custom fields: %w", err))
+		return
+	}
+	processFieldID, skipFieldID := -1, -1
+	for _, f := range fields {
+		switch f.Name {
+		case fieldName:
+			processFieldID = f.ID
+		case skipFieldName:
+			skipFieldID = f.ID
+		}
+	}
+	if query.Get("ordering") == "" {
+		query.Set("ordering", "id")
+	}
+
+	for doc, err := range c.streamDocuments(ctx, query) {
+		if err == nil && !needsProcessing(doc, processFieldID, processID, skipFieldID) {
+			continue
+		}
+		if !yield(doc, err) {
+			return
+		}
+	}
+}
+
+// needsProcessing reports whether the document matches UnprocessedQuery: an
+// empty or non-numeric marker counts as unprocessed, like a missing one.
+func needsProcessing(doc Document, processFieldID, processID, skipFieldID int) bool {
+	for _, f := range doc.CustomFields {
+		switch f.Field {
+		case processFieldID:
+			if n, ok := f.Value.(float64); ok && int(n) >= processID {
+				return false
+			}
+		case skipFieldID:
+			if skip, ok := f.Value.(bool); ok && skip {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// DocumentsMatching streams the documents selected by the filter.
+func (c *Client) DocumentsMatching(ctx context.Context, filter DocumentFilter) iter.Seq2[Document, error] {
+	return func(yield func(Document, error) bool) {
+		query, err := c.filterValues(ctx, filter)
+		if err != nil {
+			yield(Document{}, err)
+			return
+		}
+		for doc, err := range c.streamDocuments(ctx, query) {
+			if !yield(doc, err) {
+				return
+			}
+		}
+	}
+}
+
+// streamDocuments yields documents as each page arrives, so callers can start
+// working before the whole result set is fetched.
+//
+// Callers typically update the documents they receive, which can drop them out of
+// the query (e.g. once marked processed) and shift later pages. To avoid skipping
+// documents, the result IDs listed in the first page's "all" field are taken as a
+// snapshot and the remaining documents are fetched by ID in the same order.
+// Paperless-ngx versions without "all" fall back to following the next links.
+func (c *Client) streamDocuments(ctx context.Context, query url.Values) iter.Seq2[Document, error] {
+	return func(yield func(Document, error) bool) {
+		query.Set("fields", streamFields)
+		if c.PageSize > 0 {
+			query.Set("page_size", strconv.Itoa(c.PageSize))
+		}
+
+		var remaining []int
+		stopped := false
+		err := eachPage(ctx, c, "/api/documents/", query, func(page listResponse[Document]) bool {
+			yielded := make(map[int]bool, len(page.Results))
+			for _, doc := range page.Results {
+				if !yield(doc, nil) {
+					stopped = true
+					return false
+				}
+				yielded[doc.ID] = true
+			}
+			if page.Next == nil || page.All == nil {
+				return true
+			}
+			for _, id := range page.All {
+				if !yielded[id] {
+					remaining = append(remaining, id)
+				}
+			}
+			return false
+		})
+		if stopped {
+			return
+		}
+		if err != nil {
+			yield(Document{}, err)
+			return
+		}
+
+		chunk := c.PageSize
+		if chunk <= 0 {
+			chunk = DefaultPageSize
+		}
+		for len(remaining) > 0 {
+			ids := remaining[:min(chunk, len(remaining))]
+			remaining = remaining[len(ids):]
+
+			docs, err := listAll[Document](ctx, c, "/api/documents/", url.Values{
+				"id__in": {joinIDs(ids)},
+				"fields": {streamFields},
+			})
+			if err != nil {
+				yield(Document{}, fmt.Errorf("fetching documents by ID: %w", err))
+				return
+			}
+			byID := make(map[int]Document, len(docs))
+			for _, doc := range docs {
+				byID[doc.ID] = doc
+			}
+			for _, id := range ids {
+				// Documents deleted since the snapshot are skipped
+				doc, ok := byID[id]
+				if !ok {
+					continue
+				}
+				if !yield(doc, nil) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package paperless
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+const (
+	testProcessField = 1
+	testSkipField    = 2
+	testProcessID    = 3
+)
+
+// matchQuery evaluates a custom_field_query against a document the way
+// Paperless-ngx does, for the operators UnprocessedQuery uses.
+func matchQuery(t *testing.T, query any, doc Document, fieldIDs map[string]int) bool {
+	t.Helper()
+	q := query.([]any)
+	switch q[0] {
+	case "AND", "OR":
+		and := q[0] == "AND"
+		for _, sub := range q[1].([]any) {
+			if matchQuery(t, sub, doc, fieldIDs) != and {
+				return !and
+			}
+		}
+		return and
+	case "NOT":
+		return !matchQuery(t, q[1], doc, fieldIDs)
+	}
+
+	var (
+		value    any
+		attached bool
+	)
+	for _, f := range doc.CustomFields {
+		if f.Field == fieldIDs[q[0].(string)] {
+			value, attached = f.Value, true
+		}
+	}
+	switch q[1] {
+	case "exists":
+		return attached == q[2].(bool)
+	case "isnull":
+		return attached && (value == nil) == q[2].(bool)
+	case "lt":
+		n, ok := value.(float64)
+		return ok && n < q[2].(float64)
+	case "exact":
+		return attached && value == q[2]
+	}
+	t.Fatalf("unsupported query %v", q)
+	return false
+}
+
+func TestNeedsProcessingMatchesUnprocessedQuery(t *testing.T) {
+	var query any
+	if err := json.Unmarshal(UnprocessedQuery("process", testProcessID, "skip"), &query); err != nil {
+		t.Fatal(err)
+	}
+	fieldIDs := map[string]int{"process": testProcessField, "skip": testSkipField}
+
+	tests := []struct {
+		name   string
+		fields []CustomFieldValue
+		want   bool
+	}{
+		{"no fields", nil, true},
+		{"marker null", []CustomFieldValue{{Field: testProcessField}}, true},
+		{"marker older", []CustomFieldValue{{Field: testProcessField, Value: float64(testProcessID - 1)}}, true},
+		{"marker current", []CustomFieldValue{{Field: testProcessField, Value: float64(testProcessID)}}, false},
+		{"marker newer", []CustomFieldValue{{Field: testProcessField, Value: float64(testProcessID + 1)}}, false},
+		{"skip true", []CustomFieldValue{{Field: testSkipField, Value: true}}, false},
+		{"skip false", []CustomFieldValue{{Field: testSkipField, Value: false}}, true},
+		{"skip null", []CustomFieldValue{{Field: testSkipField}}, true},
+		{"marker null and skip true", []CustomFieldValue{{Field: testProcessField}, {Field: testSkipField, Value: true}}, false},
+		{"other field", []CustomFieldValue{{Field: 9, Value: float64(testProcessID)}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := Document{CustomFields: tt.fields}
+			if got := matchQuery(t, query, doc, fieldIDs); got != tt.want {
+				t.Errorf("UnprocessedQuery matches = %v, want %v", got, tt.want)
+			}
+			if got := needsProcessing(doc, testProcessField, testProcessID, testSkipField); got != tt.want {
+				t.Errorf("needsProcessing = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnprocessedQueryWithoutSkipField(t *testing.T) {
+	got := string(UnprocessedQuery("process", testProcessID, ""))
+	want := fmt.Sprintf(`["OR",[["process","exists",false],["process","isnull",true],["process","lt",%d]]]`, testProcessID)
+	if got != want {
+		t.Errorf("UnprocessedQuery = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,269 @@
+package paperless
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TransportOptions controls timeouts, retries and rate limiting for Paperless-ngx requests.
+type TransportOptions struct {
+	// Timeout bounds each individual attempt, including reading the response body. 0 means no timeout.
+	Timeout time.Duration
+	// MaxRetries is the number of additional attempts after a retryable failure.
+	MaxRetries int
+	// MinBackoff and MaxBackoff bound the exponential backoff between attempts.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// RateLimit is the maximum number of requests per second. 0 means unlimited.
+	RateLimit float64
+}
+
+// DefaultTransportOptions returns the settings used by NewClient.
+func DefaultTransportOptions() TransportOptions {
+	return TransportOptions{
+		Timeout:    2 * time.Minute,
+		MaxRetries: 4,
+		MinBackoff: 500 * time.Millisecond,
+		MaxBackoff: 30 * time.Second,
+	}
+}
+
+// TransportOptionsFromEnv starts from DefaultTransportOptions and applies
+// PAPERLESS_TIMEOUT (duration), PAPERLESS_MAX_RETRIES and PAPERLESS_RATE_LIMIT (requests/second).
+func TransportOptionsFromEnv() (TransportOptions, error) {
+	opts := DefaultTransportOptions()
+	if v := os.Getenv("PAPERLESS_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return opts, fmt.Errorf("PAPERLESS_TIMEOUT: %w", err)
+		}
+		opts.Timeout = d
+	}
+	if v := os.Getenv("PAPERLESS_MAX_RETRIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("PAPERLESS_MAX_RETRIES: invalid value %q", v)
+		}
+		opts.MaxRetries = n
+	}
+	if v := os.Getenv("PAPERLESS_RATE_LIMIT"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return opts, fmt.Errorf("PAPERLESS_RATE_LIMIT: invalid value %q", v)
+		}
+		opts.RateLimit = f
+	}
+	return opts, nil
+}
+
+type retrySafeKey struct{}
+
+// withRetrySafe marks a non-idempotent request (POST/PATCH) as safe to repeat.
+// A PATCH that sets absolute values, such as a document or custom field update,
+// is: repeating it after a failed attempt is harmless.
+func withRetrySafe(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retrySafeKey{}, true)
+}
+
+// retryTransport is an http.RoundTripper that rate limits requests, applies a
+// per-attempt timeout and retries failures that are safe to retry.
+type retryTransport struct {
+	base    http.RoundTripper
+	opts    TransportOptions
+	limiter *rateLimiter
+}
+
+func newRetryTransport(base http.RoundTripper, opts TransportOptions) *retryTransport {
+	return &retryTransport{
+		base:    base,
+		opts:    opts,
+		limiter: newRateLimiter(opts.RateLimit),
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	safe := isIdempotent(req.Method) || ctx.Value(retrySafeKey{}) == true
+
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		attemptReq, cancel, err := t.prepareAttempt(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		retry, wait := t.shouldRetry(req, resp, err, safe)
+		if !retry || attempt >= t.opts.MaxRetries || req.Body != nil && req.GetBody == nil {
+			if resp != nil {
+				resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+			} else {
+				cancel()
+			}
+			return resp, err
+		}
+
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		cancel()
+
+		if wait == 0 {
+			wait = t.backoff(attempt)
+		}
+		wait = min(wait, t.opts.MaxBackoff)
+		slog.WarnContext(ctx, "Paperless request failed, retrying",
+			"method", req.Method, "path", req.URL.Path, "reason", reason,
+			"wait", wait.Round(time.Millisecond), "attempt", attempt+1, "max_retries", t.opts.MaxRetries)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// prepareAttempt clones req with a fresh body and the per-attempt timeout.
+func (t *retryTransport) prepareAttempt(req *http.Request, attempt int) (*http.Request, context.CancelFunc, error) {
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if t.opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.opts.Timeout)
+	}
+	attemptReq := req.Clone(ctx)
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, nil, fmt.Errorf("rewinding request body: %w", err)
+		}
+		attemptReq.Body = body
+	}
+	return attemptReq, cancel, nil
+}
+
+// shouldRetry decides whether an attempt can be repeated and returns any
+// server-requested delay. Requests that are not safe to repeat are only retried
+// when Paperless cannot have acted on them: failures to connect, and 429 Too Many
+// Requests. A 502 or 503 may come from a proxy after Paperless has already
+// handled the request, so it is only retried for safe requests.
+func (t *retryTransport) shouldRetry(req *http.Request, resp *http.Response, err error, safe bool) (bool, time.Duration) {
+	if err != nil {
+		if req.Context().Err() != nil {
+			return false, 0
+		}
+		if safe {
+			return true, 0
+		}
+		var opErr *net.OpError
+		return errors.As(err, &opErr) && opErr.Op == "dial", 0
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true, retryAfter(resp)
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return safe, retryAfter(resp)
+	}
+	return false, 0
+}
+
+// backoff returns the exponential backoff with jitter for the given attempt.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	d := t.opts.MinBackoff << attempt
+	if d <= 0 || d > t.opts.MaxBackoff {
+		d = t.opts.MaxBackoff
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryAfter parses a Retry-After header given in seconds.
+func retryAfter(resp *http.Response) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// cancelOnClose releases an attempt's timeout context once the response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// rateLimiter spaces requests at least 1/rate seconds apart. A nil limiter never waits.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,176 @@
+package paperless
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingTransport counts the attempts the retry transport makes.
+type countingTransport struct {
+	attempts atomic.Int32
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.attempts.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func testTransportOptions() TransportOptions {
+	return TransportOptions{MaxRetries: 2, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+}
+
+// failingServer responds with status to the first request and 200 to the rest.
+func failingServer(t *testing.T, status int) *httptest.Server {
+	t.Helper()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRetryTransportStatuses(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		safe         bool
+		status       int
+		wantAttempts int32
+		wantStatus   int
+	}{
+		{"429 on POST", http.MethodPost, false, http.StatusTooManyRequests, 2, http.StatusOK},
+		{"503 on PATCH", http.MethodPatch, false, http.StatusServiceUnavailable, 1, http.StatusServiceUnavailable},
+		{"502 on PATCH", http.MethodPatch, false, http.StatusBadGateway, 1, http.StatusBadGateway},
+		{"503 on retry-safe PATCH", http.MethodPatch, true, http.StatusServiceUnavailable, 2, http.StatusOK},
+		{"500 on GET", http.MethodGet, false, http.StatusInternalServerError, 2, http.StatusOK},
+		{"404 on GET", http.MethodGet, false, http.StatusNotFound, 1, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := failingServer(t, tt.status)
+			base := &countingTransport{}
+			client := &http.Client{Transport: newRetryTransport(base, testTransportOptions())}
+
+			ctx := context.Background()
+			if tt.safe {
+				ctx = withRetrySafe(ctx)
+			}
+			req, err := http.NewRequestWithContext(ctx, tt.method, srv.URL, strings.NewReader(`{"title":"x"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := base.attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryTransportRetriesDialErrors(t *testing.T) {
+	// A closed listener's address refuses connections.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	base := &countingTransport{}
+	client := &http.Client{Transport: newRetryTransport(base, testTransportOptions())}
+	req, err := http.NewRequest(http.MethodPost, "http://"+addr+"/api/tags/", strings.NewReader(`{"name":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("Do succeeded, want dial error")
+	}
+	if got, want := base.attempts.Load(), int32(testTransportOptions().MaxRetries+1); got != want {
+		t.Errorf("attempts = %d, want %d", got, want)
+	}
+}
+
+func TestRetryTransportRetryAfter(t *testing.T) {
+	tr := newRetryTransport(http.DefaultTransport, testTransportOptions())
+	req := httptest.NewRequest(http.MethodPost, "/api/documents/bulk_edit/", nil)
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"7"}}}
+	retry, wait := tr.shouldRetry(req, resp, nil, false)
+	if !retry || wait != 7*time.Second {
+		t.Errorf("shouldRetry = %v, %v, want true, 7s", retry, wait)
+	}
+
+	resp.Header.Set("Retry-After", "Wed, 21 Oct 2015 07:28:00 GMT")
+	if _, wait := tr.shouldRetry(req, resp, nil, false); wait != 0 {
+		t.Errorf("wait for an HTTP-date Retry-After = %v, want 0 (backoff)", wait)
+	}
+}
+
+func TestRetryTransportPerAttemptTimeout(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			// Hang until the attempt times out
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	opts := testTransportOptions()
+	opts.Timeout = 50 * time.Millisecond
+	base := &countingTransport{}
+	client := &http.Client{Transport: newRetryTransport(base, opts)}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	resp.Body.Close()
+	if got := base.attempts.Load(); got != 2 {
+		t.Errorf("attempts = %d, want 2", got)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	if err := newRateLimiter(0).wait(context.Background()); err != nil {
+		t.Errorf("unlimited wait: %v", err)
+	}
+
+	l := newRateLimiter(20)
+	start := time.Now()
+	for range 3 {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first request goes at once, the next two 50ms apart.
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 requests at 20/s took %v, want at least 100ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l = newRateLimiter(0.1)
+	l.wait(ctx) // takes the free slot
+	if err := l.wait(ctx); err == nil {
+		t.Error("wait with a canceled context succeeded, want error")
+	}
+}
@@ -0,0 +1,190 @@
+package paperless
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MaxAPIVersion is the newest Paperless-ngx REST API version this client is written
+// against. Detect negotiates the lower of this and the server's version.
+const MaxAPIVersion = 7
+
+// Capability is an optional Paperless-ngx feature the client relies on.
+type Capability string
+
+const (
+	// CapCustomFields is custom field support on documents (2.0).
+	CapCustomFields Capability = "custom_fields"
+	// CapCustomFieldQuery is the custom_field_query documents filter (2.13).
+	CapCustomFieldQuery Capability = "custom_field_query"
+	// CapBulkCustomFieldValues is setting custom field values through bulk_edit (2.15).
+	CapBulkCustomFieldValues Capability = "bulk_custom_field_values"
+	// CapNotes is the document notes API (1.13).
+	CapNotes Capability = "notes"
+	// CapSelectOptionIDs means select custom field options are {id, label} objects
+	// and values reference the option ID (API version 7).
+	CapSelectOptionIDs Capability = "select_option_ids"
+)
+
+// capabilityVersions is the first Paperless-ngx release providing each capability.
+var capabilityVersions = map[Capability][3]int{
+	CapCustomFields:          {2, 0, 0},
+	CapCustomFieldQuery:      {2, 13, 0},
+	CapBulkCustomFieldValues: {2, 15, 0},
+	CapNotes:                 {1, 13, 0},
+}
+
+// ServerInfo describes the Paperless-ngx server the client talks to.
+type ServerInfo struct {
+	// Version is the Paperless-ngx release, e.g. "2.15.3".
+	Version string
+	// APIVersion is the highest REST API version the server supports.
+	APIVersion   int
+	Capabilities map[Capability]bool
+}
+
+// Has reports whether the server provides the capability.
+func (s ServerInfo) Has(c Capability) bool {
+	return s.Capabilities[c]
+}
+
+// Supports reports whether the server provides the capability. Before Detect has
+// run every capability is assumed to be present.
+func (c *Client) Supports(cap Capability) bool {
+	return c.Server == nil || c.Server.Has(cap)
+}
+
+// setHeaders adds the authorization header and, once negotiated, the API version.
+func (c *Client) setHeaders(req *http.Request) {
+	req.Header.Set("Authorization", "Token "+c.Token)
+	if c.APIVersion > 0 {
+		req.Header.Set("Accept", "application/json; version="+strconv.Itoa(c.APIVersion))
+	}
+}
+
+// Detect reads the server's release and API version, records its capabilities in
+// c.Server and negotiates the API version used for later requests.
+//
+// The versions come from the X-Version and X-Api-Version headers Paperless-ngx adds
+// to authenticated API responses, read from /api/ui_settings/. Servers that omit the
+// release header are asked through /api/status/, which requires an admin token.
+func (c *Client) Detect(ctx context.Context) (*ServerInfo, error) {
+	resp, err := c.get(ctx, "/api/ui_settings/")
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	info := &ServerInfo{Version: resp.Header.Get("X-Version")}
+	if v := resp.Header.Get("X-Api-Version"); v != "" {
+		if info.APIVersion, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid X-Api-Version header %q", v)
+		}
+	}
+
+	if info.Version == "" {
+		resp, err := c.get(ctx, "/api/status/")
+		if err != nil {
+			return nil, fmt.Errorf("no X-Version header and %w", err)
+		}
+		var status struct {
+			Version string `json:"pngx_version"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&status)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decoding status: %w", err)
+		}
+		info.Version = status.Version
+	}
+
+	release, ok := parseVersion(info.Version)
+	if !ok {
+		return nil, fmt.Errorf("cannot determine Paperless-ngx version (got %q)", info.Version)
+	}
+	info.Capabilities = make(map[Capability]bool)
+	for cap, since := range capabilityVersions {
+		info.Capabilities[cap] = compareVersions(release, since) >= 0
+	}
+
+	c.APIVersion = min(info.APIVersion, MaxAPIVersion)
+	info.Capabilities[CapSelectOptionIDs] = c.APIVersion >= 7
+	c.Server = info
+	return info, nil
+}
+
+// get performs an authenticated GET and returns the response if it is 200 OK.
+func (c *Client) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	c.setHeaders(req)
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, &StatusError{StatusCode: resp.StatusCode, Path: path, Body: string(body)}
+	}
+	return resp, nil
+}
+
+// Ping verifies that Paperless-ngx responds and accepts the token.
+func (c *Client) Ping(ctx context.Context) error {
+	resp, err := c.get(ctx, "/api/ui_settings/")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// StatusError is an unexpected HTTP status from Paperless-ngx.
+type StatusError struct {
+	StatusCode int
+	Path       string
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("paperless returned status %d for %s: %s", e.StatusCode, e.Path, e.Body)
+}
+
+// parseVersion parses a release such as "2.15.3" or "v2.15.0-beta.rc1".
+func parseVersion(s string) ([3]int, bool) {
+	var v [3]int
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+ "); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
+
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return 0
+}
@@ -0,0 +1,107 @@
+// Package pipeline holds the document analysis steps shared by the batch
+// processor and the server.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/bartlettc22/paperless-llm-processor/internal/converter"
+	"github.com/bartlettc22/paperless-llm-processor/internal/logging"
+	"github.com/bartlettc22/paperless-llm-processor/internal/metrics"
+	"github.com/bartlettc22/paperless-llm-processor/internal/ollama"
+)
+
+// Progress receives per-page progress. *jobs.Job implements it.
+type Progress interface {
+	StartPage(i int)
+	FinishPage(i int, err error)
+}
+
+// AnalyzePages runs a structured analysis of every page and merges the results.
+// Pages with tiles get their transcription from the tiles instead. progress may be nil.
+func AnalyzePages(ctx context.Context, client *ollama.Client, pages []converter.Page, choices ollama.Choices, progress Progress) (ollama.DocumentAnalysis, error) {
+	results := make([]ollama.DocumentAnalysis, 0, len(pages))
+	for i, page := range pages {
+		ctx := logging.With(ctx, logging.KeyPage, i+1)
+		slog.InfoContext(ctx, "analyzing page", "pages", len(pages))
+		if progress != nil {
+			progress.StartPage(i)
+		}
+		result, err := analyzePage(ctx, client, page, choices)
+		if progress != nil {
+			progress.FinishPage(i, err)
+		}
+		if err != nil {
+			return ollama.DocumentAnalysis{}, fmt.Errorf("page %d: %w", i+1, err)
+		}
+		metrics.PagesProcessed.WithLabelValues(client.Model).Inc()
+		results = append(results, result)
+	}
+	return Merge(results), nil
+}
+
+func analyzePage(ctx context.Context, client *ollama.Client, page converter.Page, choices ollama.Choices) (ollama.DocumentAnalysis, error) {
+	result, err := client.AnalyzeStructured(ctx, page.Image, choices)
+	if err != nil {
+		return ollama.DocumentAnalysis{}, fmt.Errorf("analyzing: %w", err)
+	}
+
+	// Replace the whole-page transcription with the high-resolution tiled one
+	if len(page.Tiles) > 0 {
+		text, err := client.TranscribeTiles(ctx, page.Tiles, page.Cols, page.Rows)
+		if err != nil {
+			return ollama.DocumentAnalysis{}, fmt.Errorf("transcribing tiles: %w", err)
+		}
+		result.Transcription = text
+	}
+	return *result, nil
+}
+
+// Merge combines per-page analyses into one: metadata comes from the first page
+// that provides it, summaries and transcriptions are concatenated and tags are
+// deduplicated.
+func Merge(pages []ollama.DocumentAnalysis) ollama.DocumentAnalysis {
+	var merged ollama.DocumentAnalysis
+	var summaries []string
+	var transcriptions []string
+	seenTags := make(map[string]bool)
+
+	for _, page := range pages {
+		if page.Summary != "" {
+			summaries = append(summaries, page.Summary)
+		}
+		if page.Transcription != "" {
+			transcriptions = append(transcriptions, page.Transcription)
+		}
+
+		if merged.FileName == "" && page.FileName != "" {
+			merged.FileName = page.FileName
+		}
+		if merged.DocumentType == "" && page.DocumentType != "" {
+			merged.DocumentType = page.DocumentType
+		}
+		if merged.DocumentDate == "" && page.DocumentDate != "" {
+			merged.DocumentDate = page.DocumentDate
+		}
+		if merged.Correspondent == "" && page.Correspondent != "" {
+			merged.Correspondent = page.Correspondent
+		}
+		if merged.StoragePath == "" && page.StoragePath != "" {
+			merged.StoragePath = page.StoragePath
+		}
+
+		for _, t := range page.Tags {
+			if t != "" && !seenTags[t] {
+				seenTags[t] = true
+				merged.Tags = append(merged.Tags, t)
+			}
+		}
+	}
+
+	merged.Summary = strings.Join(summaries, "\n\n")
+	merged.Transcription = strings.Join(transcriptions, "\n\n")
+	return merged
+}
@@ -0,0 +1,41 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bartlettc22/paperless-llm-processor/internal/ollama"
+	"github.com/bartlettc22/paperless-llm-processor/internal/paperless"
+)
+
+// SummaryNoteHeader starts every note written by the processor. Notes without it
+// were written by people and are never modified.
+const SummaryNoteHeader = "LLM summary (paperless-llm-processor)"
+
+// WriteSummaryNote replaces any previous processor-written note on the document
+// with one containing the summary and processing details. The new note is added
+// before the old ones are deleted, so a failure never leaves the document
+// without a summary note.
+func WriteSummaryNote(ctx context.Context, client *paperless.Client, docID int, summary, model string) error {
+	notes, err := client.ListNotes(ctx, docID)
+	if err != nil {
+		return fmt.Errorf("listing notes: %w", err)
+	}
+
+	text := fmt.Sprintf("%s\n\n%s\n\nModel: %s\nPrompt version: %s\nProcessed: %s",
+		SummaryNoteHeader, summary, model, ollama.PromptVersion(), time.Now().UTC().Format(time.RFC3339))
+	if _, err := client.AddNote(ctx, docID, text); err != nil {
+		return fmt.Errorf("adding summary note: %w", err)
+	}
+
+	for _, n := range notes {
+		if strings.HasPrefix(n.Note, SummaryNoteHeader) {
+			if err := client.DeleteNote(ctx, docID, n.ID); err != nil {
+				return fmt.Errorf("deleting previous summary note %d: %w", n.ID, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bartlettc22/paperless-llm-processor/internal/ollama"
+	"github.com/bartlettc22/paperless-llm-processor/internal/paperless"
+)
+
+// PermissionRule assigns an owner and permissions to documents whose extracted
+// Field contains Contains (case-insensitive). Field is one of correspondent, tags,
+// document_type, summary or transcription. Rules are tried in order and the first
+// match wins.
+type PermissionRule struct {
+	Field    string         `json:"field"`
+	Contains string         `json:"contains"`
+	Owner    string         `json:"owner"`
+	View     PrincipalNames `json:"view"`
+	Change   PrincipalNames `json:"change"`
+
+	ownerID     *int
+	permissions *paperless.Permissions
+}
+
+// PrincipalNames lists users (by username) and groups (by name).
+type PrincipalNames struct {
+	Users  []string `json:"users"`
+	Groups []string `json:"groups"`
+}
+
+// LoadPermissionRules reads a JSON array of rules from path and resolves every
+// username and group name to its Paperless-ngx ID.
+func LoadPermissionRules(path string, users []paperless.User, groups []paperless.Group) ([]PermissionRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading permission rules: %w", err)
+	}
+	var rules []PermissionRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parsing permission rules %s: %w", path, err)
+	}
+
+	userIDs := make(map[string]int, len(users))
+	for _, u := range users {
+		userIDs[u.Username] = u.ID
+	}
+	groupIDs := make(map[string]int, len(groups))
+	for _, g := range groups {
+		groupIDs[g.Name] = g.ID
+	}
+
+	for i := range rules {
+		r := &rules[i]
+		switch r.Field {
+		case "correspondent", "tags", "document_type", "summary", "transcription":
+		default:
+			return nil, fmt.Errorf("permission rule %d: unknown field %q", i+1, r.Field)
+		}
+		if r.Contains == "" {
+			return nil, fmt.Errorf("permission rule %d: contains must not be empty", i+1)
+		}
+
+		if r.Owner != "" {
+			id, ok := userIDs[r.Owner]
+			if !ok {
+				return nil, fmt.Errorf("permission rule %d: unknown owner %q", i+1, r.Owner)
+			}
+			r.ownerID = &id
+		}
+
+		if len(r.View.Users)+len(r.View.Groups)+len(r.Change.Users)+len(r.Change.Groups) > 0 {
+			view, err := resolvePrincipals(r.View, userIDs, groupIDs)
+			if err != nil {
+				return nil, fmt.Errorf("permission rule %d view: %w", i+1, err)
+			}
+			change, err := resolvePrincipals(r.Change, userIDs, groupIDs)
+			if err != nil {
+				return nil, fmt.Errorf("permission rule %d change: %w", i+1, err)
+			}
+			r.permissions = &paperless.Permissions{View: view, Change: change}
+		}
+	}
+	return rules, nil
+}
+
+func resolvePrincipals(names PrincipalNames, userIDs, groupIDs map[string]int) (paperless.PermissionSet, error) {
+	set := paperless.PermissionSet{Users: []int{}, Groups: []int{}}
+	for _, name := range names.Users {
+		id, ok := userIDs[name]
+		if !ok {
+			return set, fmt.Errorf("unknown user %q", name)
+		}
+		set.Users = append(set.Users, id)
+	}
+	for _, name := range names.Groups {
+		id, ok := groupIDs[name]
+		if !ok {
+			return set, fmt.Errorf("unknown group %q", name)
+		}
+		set.Groups = append(set.Groups, id)
+	}
+	return set, nil
+}
+
+// MatchPermissionRule returns the first rule matching the analysis, or nil.
+func MatchPermissionRule(rules []PermissionRule, a ollama.DocumentAnalysis) *PermissionRule {
+	for i := range rules {
+		r := &rules[i]
+		var values []string
+		switch r.Field {
+		case "correspondent":
+			values = []string{a.Correspondent}
+		case "tags":
+			values = a.Tags
+		case "document_type":
+			values = []string{a.DocumentType}
+		case "summary":
+			values = []string{a.Summary}
+		case "transcription":
+			values = []string{a.Transcription}
+		}
+		for _, v := range values {
+			if strings.Contains(strings.ToLower(v), strings.ToLower(r.Contains)) {
+				return r
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bartlettc22/paperless-llm-processor/internal/ollama"
+	"github.com/bartlettc22/paperless-llm-processor/internal/paperless"
+)
+
+// Rules choose a document's storage path, owner and permissions. The zero value
+// leaves all three alone.
+type Rules struct {
+	// StoragePathMode is StoragePathRules or StoragePathLLM, or empty to leave
+	// storage paths alone.
+	StoragePathMode string
+	StoragePaths    []StoragePathRule
+	// Permissions are tried in order; the first match sets the owner and permissions.
+	Permissions []PermissionRule
+}
+
+// LoadRules checks storagePathMode and reads the rule files. storagePathRules is
+// the storage path rules file used in StoragePathRules mode and permissionRules
+// the permission rules file; an empty permissionRules leaves ownership alone.
+func LoadRules(ctx context.Context, client *paperless.Client, storagePathMode, storagePathRules, permissionRules string) (Rules, error) {
+	r := Rules{StoragePathMode: storagePathMode}
+	switch storagePathMode {
+	case "", StoragePathLLM:
+	case StoragePathRules:
+		if storagePathRules == "" {
+			return r, fmt.Errorf("storage path mode %s requires a storage path rules file", StoragePathRules)
+		}
+		storagePaths, err := client.ListStoragePaths(ctx)
+		if err != nil {
+			return r, fmt.Errorf("listing storage paths: %w", err)
+		}
+		idByName := make(map[string]int, len(storagePaths))
+		for _, sp := range storagePaths {
+			idByName[sp.Name] = sp.ID
+		}
+		if r.StoragePaths, err = LoadStoragePathRules(storagePathRules, idByName); err != nil {
+			return r, err
+		}
+	default:
+		return r, fmt.Errorf("invalid storage path mode %q (must be %s or %s)", storagePathMode, StoragePathRules, StoragePathLLM)
+	}
+
+	if permissionRules != "" {
+		users, err := client.ListUsers(ctx)
+		if err != nil {
+			return r, fmt.Errorf("listing users: %w", err)
+		}
+		groups, err := client.ListGroups(ctx)
+		if err != nil {
+			return r, fmt.Errorf("listing groups: %w", err)
+		}
+		if r.Permissions, err = LoadPermissionRules(permissionRules, users, groups); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// Choices returns the names the model picks from: the document types and, in
+// StoragePathLLM mode, the storage paths.
+func (r Rules) Choices(catalog *Catalog) ollama.Choices {
+	choices := ollama.Choices{DocumentTypes: catalog.DocumentTypeNames}
+	if r.StoragePathMode == StoragePathLLM {
+		choices.StoragePaths = catalog.StoragePathNames
+	}
+	return choices
+}
@@ -0,0 +1,59 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Storage path modes.
+const (
+	// StoragePathRules maps the extracted document type and correspondent
+	// through StoragePathRule entries.
+	StoragePathRules = "rules"
+	// StoragePathLLM lets the model pick from the storage path names.
+	StoragePathLLM = "llm"
+)
+
+// StoragePathRule maps a document type and/or correspondent to a storage path name.
+// Empty match fields match any value; rules are tried in order and the first match wins.
+type StoragePathRule struct {
+	DocumentType  string `json:"document_type"`
+	Correspondent string `json:"correspondent"`
+	StoragePath   string `json:"storage_path"`
+}
+
+// LoadStoragePathRules reads a JSON array of rules from path and checks that every
+// rule names an existing storage path.
+func LoadStoragePathRules(path string, storagePathIDByName map[string]int) ([]StoragePathRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading storage path rules: %w", err)
+	}
+	var rules []StoragePathRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parsing storage path rules %s: %w", path, err)
+	}
+	for i, r := range rules {
+		if _, ok := storagePathIDByName[r.StoragePath]; !ok {
+			return nil, fmt.Errorf("storage path rule %d: unknown storage path %q", i+1, r.StoragePath)
+		}
+	}
+	return rules, nil
+}
+
+// MatchStoragePath returns the storage path name of the first rule matching the
+// document type and correspondent (case-insensitively), or "" if none match.
+func MatchStoragePath(rules []StoragePathRule, documentType, correspondent string) string {
+	for _, r := range rules {
+		if r.DocumentType != "" && !strings.EqualFold(r.DocumentType, documentType) {
+			continue
+		}
+		if r.Correspondent != "" && !strings.EqualFold(r.Correspondent, correspondent) {
+			continue
+		}
+		return r.StoragePath
+	}
+	return ""
+}
@@ -0,0 +1,413 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/bartlettc22/paperless-llm-processor/internal/metrics"
+	"github.com/bartlettc22/paperless-llm-processor/internal/ollama"
+	"github.com/bartlettc22/paperless-llm-processor/internal/paperless"
+)
+
+// Processing markers and their custom fields.
+const (
+	// ProcessID is the current processing version. Documents with a lower
+	// ProcessField value are processed again.
+	ProcessID    = 5
+	ProcessField = "llm-process-id"
+	SummaryField = "llm-summary"
+	ModelField   = "llm-model"
+	SkipField    = "llm-skip"
+)
+
+// Fields are the document fields Plan can change. storage_path and permissions
+// are only changed when Rules configure them.
+var Fields = []string{"title", "document_type", "document_date", "summary", "content", "correspondent", "tags", "storage_path", "permissions"}
+
+// Catalog maps the names of Paperless-ngx document types, correspondents, tags
+// and storage paths to their IDs. EnsureCorrespondent and EnsureTag add to the maps.
+type Catalog struct {
+	DocumentTypeNames []string
+	DocumentTypes     map[string]int
+	Correspondents    map[string]int
+	Tags              map[string]int
+	StoragePathNames  []string
+	StoragePaths      map[string]int
+}
+
+// LoadCatalog fetches the document types, correspondents, tags and storage paths.
+func LoadCatalog(ctx context.Context, client *paperless.Client) (*Catalog, error) {
+	docTypes, err := client.ListDocumentTypes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing document types: %w", err)
+	}
+	corrList, err := client.ListCorrespondents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing correspondents: %w", err)
+	}
+	tagList, err := client.ListTags(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing tags: %w", err)
+	}
+	storagePaths, err := client.ListStoragePaths(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing storage paths: %w", err)
+	}
+
+	c := &Catalog{
+		DocumentTypeNames: make([]string, len(docTypes)),
+		DocumentTypes:     make(map[string]int, len(docTypes)),
+		Correspondents:    make(map[string]int, len(corrList)),
+		Tags:              make(map[string]int, len(tagList)),
+		StoragePathNames:  make([]string, len(storagePaths)),
+		StoragePaths:      make(map[string]int, len(storagePaths)),
+	}
+	for i, dt := range docTypes {
+		c.DocumentTypeNames[i] = dt.Name
+		c.DocumentTypes[dt.Name] = dt.ID
+	}
+	for _, corr := range corrList {
+		c.Correspondents[corr.Name] = corr.ID
+	}
+	for _, t := range tagList {
+		c.Tags[t.Name] = t.ID
+	}
+	for i, sp := range storagePaths {
+		c.StoragePathNames[i] = sp.Name
+		c.StoragePaths[sp.Name] = sp.ID
+	}
+	return c, nil
+}
+
+// Changes are the values an analysis sets on a document, by name. nil and empty
+// fields are left unchanged.
+type Changes struct {
+	Title         *string
+	DocumentType  *string
+	DocumentDate  *string
+	Correspondent *string
+	Content       *string
+	Summary       *string
+	Tags          []string
+	StoragePath   *string
+	// Permission is the permission rule that sets the owner and permissions.
+	Permission *PermissionRule
+	// Skipped lists requested fields left unchanged, and why.
+	Skipped []SkippedField
+}
+
+// SkippedField is a field the analysis could not set.
+type SkippedField struct {
+	Field  string `json:"field"`
+	Value  string `json:"value,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// Plan selects what to change from a merged analysis, limited to the given fields,
+// choosing the storage path and permissions by rules. Unknown document types and
+// storage paths and empty values are skipped.
+func Plan(ctx context.Context, a ollama.DocumentAnalysis, fields map[string]bool, catalog *Catalog, rules Rules) Changes {
+	var c Changes
+	if fields["title"] {
+		c.Title = &a.FileName
+	}
+	if fields["summary"] {
+		c.Summary = &a.Summary
+	}
+	if fields["content"] && a.Transcription != "" {
+		c.Content = &a.Transcription
+	}
+	if fields["document_type"] {
+		if _, ok := catalog.DocumentTypes[a.DocumentType]; ok {
+			c.DocumentType = &a.DocumentType
+		} else {
+			slog.WarnContext(ctx, "unknown document type, skipping type update", "document_type", a.DocumentType)
+			c.Skipped = append(c.Skipped, SkippedField{Field: "document_type", Value: a.DocumentType, Reason: "unknown document type"})
+		}
+	}
+	if fields["document_date"] && a.DocumentDate != "" {
+		c.DocumentDate = &a.DocumentDate
+	}
+	if fields["correspondent"] && a.Correspondent != "" {
+		c.Correspondent = &a.Correspondent
+	}
+	if fields["tags"] && len(a.Tags) > 0 {
+		c.Tags = a.Tags
+	}
+	if fields["storage_path"] && rules.StoragePathMode != "" {
+		name := a.StoragePath
+		if rules.StoragePathMode == StoragePathRules {
+			name = MatchStoragePath(rules.StoragePaths, a.DocumentType, a.Correspondent)
+		}
+		if _, ok := catalog.StoragePaths[name]; ok {
+			c.StoragePath = &name
+		} else if name != "" {
+			slog.WarnContext(ctx, "unknown storage path, skipping storage path update", "storage_path", name)
+			c.Skipped = append(c.Skipped, SkippedField{Field: "storage_path", Value: name, Reason: "unknown storage path"})
+		}
+	}
+	if fields["permissions"] {
+		c.Permission = MatchPermissionRule(rules.Permissions, a)
+	}
+	return c
+}
+
+// Processor turns planned changes into Paperless-ngx document updates.
+type Processor struct {
+	Paperless *paperless.Client
+	Catalog   *Catalog
+	// SummaryFieldID is the ID of the SummaryField custom field.
+	SummaryFieldID int
+	// DryRun resolves names without creating correspondents or tags; names not in
+	// Paperless-ngx yet are left out of the update.
+	DryRun bool
+	// Model labels the metrics for created correspondents and tags, and is
+	// recorded in summary notes.
+	Model string
+	// SummaryNote makes Update also write the summary as a document note.
+	SummaryNote bool
+	// Created, if set, is called with the kind ("correspondent" or "tag") and
+	// name of each correspondent or tag created.
+	Created func(kind, name string)
+}
+
+// BuildUpdate resolves the changes to IDs, creating missing correspondents and
+// tags unless DryRun is set. It does not add the processing markers. It also
+// returns the skipped fields: those in c.Skipped plus correspondents and tags
+// that could not be created.
+func (p *Processor) BuildUpdate(ctx context.Context, c Changes) (paperless.DocumentUpdate, []SkippedField) {
+	skipped := slices.Clone(c.Skipped)
+	var update paperless.DocumentUpdate
+	update.Title = c.Title
+	update.Content = c.Content
+	update.Created = c.DocumentDate
+	if c.Summary != nil {
+		update.CustomFields = append(update.CustomFields, paperless.CustomFieldValue{Field: p.SummaryFieldID, Value: *c.Summary})
+	}
+	if c.DocumentType != nil {
+		id := p.Catalog.DocumentTypes[*c.DocumentType]
+		update.DocumentType = &id
+	}
+
+	if c.Correspondent != nil {
+		if id, err := p.resolve(ctx, "correspondent", *c.Correspondent, p.Catalog.Correspondents, p.Paperless.EnsureCorrespondent); err == nil {
+			update.Correspondent = &id
+			slog.InfoContext(ctx, "correspondent", "correspondent", *c.Correspondent)
+		} else if !errors.Is(err, errUnresolved) {
+			skipped = append(skipped, SkippedField{Field: "correspondent", Value: *c.Correspondent, Reason: err.Error()})
+		}
+	}
+
+	if len(c.Tags) > 0 {
+		for _, name := range c.Tags {
+			if id, err := p.resolve(ctx, "tag", name, p.Catalog.Tags, p.Paperless.EnsureTag); err == nil {
+				update.Tags = append(update.Tags, id)
+			} else if !errors.Is(err, errUnresolved) {
+				skipped = append(skipped, SkippedField{Field: "tags", Value: name, Reason: err.Error()})
+			}
+		}
+		if len(update.Tags) > 0 {
+			slog.InfoContext(ctx, "tags", "tags", c.Tags)
+		}
+	}
+
+	if c.StoragePath != nil {
+		id := p.Catalog.StoragePaths[*c.StoragePath]
+		update.StoragePath = &id
+		slog.InfoContext(ctx, "storage path", "storage_path", *c.StoragePath)
+	}
+	if r := c.Permission; r != nil {
+		update.Owner = r.ownerID
+		update.SetPermissions = r.permissions
+		slog.InfoContext(ctx, "permissions", "field", r.Field, "contains", r.Contains, "owner", r.Owner)
+	}
+	return update, skipped
+}
+
+// Update patches the document and, when SummaryNote is set, writes summary as
+// its summary note. A note that cannot be written is logged but does not fail
+// the update.
+//
+// When the update changes the owner or permissions, the note is written first:
+// afterwards the processor's user may no longer be allowed to change the
+// document's notes.
+func (p *Processor) Update(ctx context.Context, docID int, update paperless.DocumentUpdate, summary string) error {
+	note := p.SummaryNote && summary != ""
+	if note && (update.Owner != nil || update.SetPermissions != nil) {
+		p.writeNote(ctx, docID, summary)
+		note = false
+	}
+	if err := p.Paperless.UpdateDocument(ctx, docID, update); err != nil {
+		return err
+	}
+	if note {
+		p.writeNote(ctx, docID, summary)
+	}
+	return nil
+}
+
+// writeNote writes the summary note, logging any failure.
+func (p *Processor) writeNote(ctx context.Context, docID int, summary string) {
+	if err := WriteSummaryNote(ctx, p.Paperless, docID, summary, p.Model); err != nil {
+		slog.WarnContext(ctx, "failed to write summary note", "error", err)
+	}
+}
+
+// errUnresolved is returned by resolve for names not in Paperless-ngx in dry-run mode.
+var errUnresolved = errors.New("not in Paperless-ngx")
+
+// resolve looks up a name, creating it with ensure unless in dry-run mode.
+// kind is "correspondent" or "tag".
+func (p *Processor) resolve(ctx context.Context, kind, name string, existing map[string]int, ensure func(context.Context, string, map[string]int) (int, error)) (int, error) {
+	if id, ok := existing[name]; ok {
+		return id, nil
+	}
+	if p.DryRun {
+		return 0, errUnresolved
+	}
+	id, err := ensure(ctx, name, existing)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to create "+kind, "name", name, "error", err)
+		return 0, fmt.Errorf("creating %s: %w", kind, err)
+	}
+	metrics.Created.WithLabelValues(p.Model, kind).Inc()
+	if p.Created != nil {
+		p.Created(kind, name)
+	}
+	return id, nil
+}
+
+// State is a document's current values for the fields Plan can change, by name.
+type State struct {
+	Title         string   `json:"title"`
+	DocumentType  string   `json:"document_type"`
+	DocumentDate  string   `json:"document_date"`
+	Correspondent string   `json:"correspondent"`
+	Content       string   `json:"content"`
+	Summary       string   `json:"summary"`
+	Tags          []string `json:"tags"`
+	StoragePath   string   `json:"storage_path"`
+}
+
+// StateOf reads a document's current state, naming IDs through the catalog.
+func StateOf(doc paperless.Document, catalog *Catalog, summaryFieldID int) State {
+	s := State{Title: doc.Title, Content: doc.Content, Tags: []string{}}
+	if len(doc.Created) >= 10 {
+		s.DocumentDate = doc.Created[:10]
+	}
+	if doc.DocumentType != nil {
+		s.DocumentType = nameOf(catalog.DocumentTypes, *doc.DocumentType)
+	}
+	if doc.Correspondent != nil {
+		s.Correspondent = nameOf(catalog.Correspondents, *doc.Correspondent)
+	}
+	if doc.StoragePath != nil {
+		s.StoragePath = nameOf(catalog.StoragePaths, *doc.StoragePath)
+	}
+	for _, id := range doc.Tags {
+		s.Tags = append(s.Tags, nameOf(catalog.Tags, id))
+	}
+	for _, f := range doc.CustomFields {
+		if f.Field == summaryFieldID && f.Value != nil {
+			s.Summary = fmt.Sprint(f.Value)
+		}
+	}
+	return s
+}
+
+// Apply returns the state after the changes. Tags replace the current tags, as
+// a document update does.
+func (s State) Apply(c Changes) State {
+	set := func(dst *string, v *string) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+	set(&s.Title, c.Title)
+	set(&s.DocumentType, c.DocumentType)
+	set(&s.DocumentDate, c.DocumentDate)
+	set(&s.Correspondent, c.Correspondent)
+	set(&s.Content, c.Content)
+	set(&s.Summary, c.Summary)
+	set(&s.StoragePath, c.StoragePath)
+	if len(c.Tags) > 0 {
+		s.Tags = c.Tags
+	}
+	return s
+}
+
+// ApplyUpdate returns the state after a document update built by BuildUpdate,
+// naming IDs through the catalog. Unlike Apply, it leaves out correspondents and
+// tags that could not be resolved.
+func (s State) ApplyUpdate(u paperless.DocumentUpdate, catalog *Catalog, summaryFieldID int) State {
+	if u.Title != nil {
+		s.Title = *u.Title
+	}
+	if u.Content != nil {
+		s.Content = *u.Content
+	}
+	if u.Created != nil {
+		s.DocumentDate = *u.Created
+	}
+	if u.DocumentType != nil {
+		s.DocumentType = nameOf(catalog.DocumentTypes, *u.DocumentType)
+	}
+	if u.Correspondent != nil {
+		s.Correspondent = nameOf(catalog.Correspondents, *u.Correspondent)
+	}
+	if u.StoragePath != nil {
+		s.StoragePath = nameOf(catalog.StoragePaths, *u.StoragePath)
+	}
+	if len(u.Tags) > 0 {
+		s.Tags = make([]string, len(u.Tags))
+		for i, id := range u.Tags {
+			s.Tags[i] = nameOf(catalog.Tags, id)
+		}
+	}
+	for _, f := range u.CustomFields {
+		if f.Field == summaryFieldID && f.Value != nil {
+			s.Summary = fmt.Sprint(f.Value)
+		}
+	}
+	return s
+}
+
+// FieldChange is one field that differs between two states.
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Diff lists the fields that differ from before to after.
+func Diff(before, after State) []FieldChange {
+	changes := []FieldChange{}
+	add := func(field string, b, a string) {
+		if b != a {
+			changes = append(changes, FieldChange{Field: field, Before: b, After: a})
+		}
+	}
+	add("title", before.Title, after.Title)
+	add("document_type", before.DocumentType, after.DocumentType)
+	add("document_date", before.DocumentDate, after.DocumentDate)
+	add("correspondent", before.Correspondent, after.Correspondent)
+	add("content", before.Content, after.Content)
+	add("summary", before.Summary, after.Summary)
+	add("storage_path", before.StoragePath, after.StoragePath)
+	if !slices.Equal(before.Tags, after.Tags) {
+		changes = append(changes, FieldChange{Field: "tags", Before: before.Tags, After: after.Tags})
+	}
+	return changes
+}
+
+func nameOf(idByName map[string]int, id int) string {
+	for name, v := range idByName {
+		if v == id {
+			return name
+		}
+	}
+	return fmt.Sprintf("#%d", id)
+}